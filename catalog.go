@@ -0,0 +1,161 @@
+package httputil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/CzarSimon/httputil/id"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// DefaultErrorCatalog catalog used by RegisterErrorCode, Coded and Codedf.
+var DefaultErrorCatalog = NewErrorCatalog()
+
+// ErrorCode stable application level error code with a default status and message.
+type ErrorCode struct {
+	Code    string `json:"code"`
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// New creates a new Error with the code wrapping the supplied error.
+func (ec ErrorCode) New(err error) *Error {
+	return &Error{
+		ID:      id.New(),
+		Code:    ec.Code,
+		Status:  ec.Status,
+		Message: ec.Message,
+		Err:     err,
+		stack:   callers(ec.Status),
+	}
+}
+
+// Newf creates a new Error with the code wrapping a formated error.
+func (ec ErrorCode) Newf(format string, a ...interface{}) *Error {
+	return ec.New(fmt.Errorf(format, a...))
+}
+
+// ErrorCatalog registry of error codes.
+type ErrorCatalog struct {
+	mu    sync.RWMutex
+	codes map[string]ErrorCode
+}
+
+// NewErrorCatalog creates a new empty ErrorCatalog.
+func NewErrorCatalog() *ErrorCatalog {
+	return &ErrorCatalog{
+		codes: make(map[string]ErrorCode),
+	}
+}
+
+// Register adds an error code to the catalog. Registering the same code again
+// is only allowed with an identical status and message.
+func (c *ErrorCatalog) Register(code string, status int, message string) (ErrorCode, error) {
+	ec := ErrorCode{
+		Code:    code,
+		Status:  status,
+		Message: message,
+	}
+	if code == "" {
+		return ErrorCode{}, fmt.Errorf("invalid error code: %v", ec)
+	}
+	if ec.Message == "" {
+		ec.Message = http.StatusText(status)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	existing, ok := c.codes[code]
+	if ok && existing != ec {
+		return ErrorCode{}, fmt.Errorf("error code %s already registered as %v", code, existing)
+	}
+
+	c.codes[code] = ec
+	return ec, nil
+}
+
+// MustRegister adds an error code to the catalog and panics if it conflicts with an existing code.
+func (c *ErrorCatalog) MustRegister(code string, status int, message string) ErrorCode {
+	ec, err := c.Register(code, status, message)
+	if err != nil {
+		errLog.Panic("Failed to register error code", zap.String("code", code), zap.Error(err))
+	}
+
+	return ec
+}
+
+// Lookup finds a registered error code.
+func (c *ErrorCatalog) Lookup(code string) (ErrorCode, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ec, ok := c.codes[code]
+	return ec, ok
+}
+
+// Codes returns all registered error codes sorted by code.
+func (c *ErrorCatalog) Codes() []ErrorCode {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	codes := make([]ErrorCode, 0, len(c.codes))
+	for _, ec := range c.codes {
+		codes = append(codes, ec)
+	}
+
+	sort.Slice(codes, func(i, j int) bool {
+		return codes[i].Code < codes[j].Code
+	})
+	return codes
+}
+
+// Coded creates an Error from a registered error code. Unknown codes
+// result in a 500 - Internal Server Error carrying the code.
+func (c *ErrorCatalog) Coded(code string, err error) *Error {
+	ec, ok := c.Lookup(code)
+	if !ok {
+		errLog.Warn("Unknown error code", zap.String("code", code))
+		ec = ErrorCode{
+			Code:    code,
+			Status:  http.StatusInternalServerError,
+			Message: http.StatusText(http.StatusInternalServerError),
+		}
+	}
+
+	return ec.New(err)
+}
+
+// ExportJSON writes the catalog as a JSON array of error codes.
+func (c *ErrorCatalog) ExportJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c.Codes())
+}
+
+// Handler creates a handler serving the catalog as JSON.
+func (c *ErrorCatalog) Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, c.Codes())
+	}
+}
+
+// RegisterErrorCode adds an error code to the DefaultErrorCatalog, panics on conflicts.
+func RegisterErrorCode(code string, status int, message string) ErrorCode {
+	return DefaultErrorCatalog.MustRegister(code, status, message)
+}
+
+// Coded creates an Error from an error code registered in the DefaultErrorCatalog.
+func Coded(code string, err error) *Error {
+	return DefaultErrorCatalog.Coded(code, err)
+}
+
+// Codedf creates an Error from an error code registered in the DefaultErrorCatalog wrapping a formated error.
+func Codedf(code string, format string, a ...interface{}) *Error {
+	return Coded(code, fmt.Errorf(format, a...))
+}
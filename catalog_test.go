@@ -0,0 +1,79 @@
+package httputil_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/CzarSimon/httputil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCatalog(t *testing.T) {
+	assert := assert.New(t)
+	catalog := httputil.NewErrorCatalog()
+
+	emailTaken, err := catalog.Register("EMAIL_TAKEN", http.StatusConflict, "Email is already taken")
+	assert.NoError(err)
+	_, err = catalog.Register("USERNAME_TAKEN", http.StatusConflict, "")
+	assert.NoError(err)
+
+	_, err = catalog.Register("EMAIL_TAKEN", http.StatusConflict, "Email is already taken")
+	assert.NoError(err)
+	_, err = catalog.Register("EMAIL_TAKEN", http.StatusBadRequest, "Email is already taken")
+	assert.Error(err)
+	_, err = catalog.Register("", http.StatusBadRequest, "Missing code")
+	assert.Error(err)
+
+	ec, ok := catalog.Lookup("USERNAME_TAKEN")
+	assert.True(ok)
+	assert.Equal("Conflict", ec.Message)
+
+	baseErr := errors.New("duplicate key")
+	codedErr := catalog.Coded("EMAIL_TAKEN", baseErr)
+	assert.Equal("EMAIL_TAKEN", codedErr.Code)
+	assert.Equal(http.StatusConflict, codedErr.Status)
+	assert.Equal("Email is already taken", codedErr.Message)
+	assert.True(errors.Is(codedErr, baseErr))
+	assert.Equal(codedErr.Code, emailTaken.New(baseErr).Code)
+
+	unknownErr := catalog.Coded("UNKNOWN", baseErr)
+	assert.Equal("UNKNOWN", unknownErr.Code)
+	assert.Equal(http.StatusInternalServerError, unknownErr.Status)
+
+	var buf bytes.Buffer
+	err = catalog.ExportJSON(&buf)
+	assert.NoError(err)
+	var exported []httputil.ErrorCode
+	err = json.Unmarshal(buf.Bytes(), &exported)
+	assert.NoError(err)
+	assert.Len(exported, 2)
+	assert.Equal("EMAIL_TAKEN", exported[0].Code)
+	assert.Equal("USERNAME_TAKEN", exported[1].Code)
+}
+
+func TestCodedErrorResponse(t *testing.T) {
+	assert := assert.New(t)
+	httputil.RegisterErrorCode("HTTPUTIL_TEST_CODE", http.StatusConflict, "Test code")
+
+	r := httputil.NewRouter("httputil-test", func() error {
+		return nil
+	})
+	r.GET("/test", func(c *gin.Context) {
+		c.Error(httputil.Codedf("HTTPUTIL_TEST_CODE", "something conflicted"))
+	})
+
+	req := createTestRequest("/test", http.MethodGet, "", nil)
+	res := performTestRequest(r, req)
+	assert.Equal(http.StatusConflict, res.Code)
+
+	var body httputil.Error
+	err := json.NewDecoder(res.Body).Decode(&body)
+	assert.NoError(err)
+	assert.Equal("HTTPUTIL_TEST_CODE", body.Code)
+	assert.Equal("Test code", body.Message)
+	assert.NotEmpty(body.ID)
+}
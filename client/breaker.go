@@ -0,0 +1,285 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CzarSimon/httputil"
+	"github.com/CzarSimon/httputil/client/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+// ErrCircuitOpen is returned when a request is rejected by an open circuit.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// Default circuit breaker settings.
+const (
+	DefaultFailureThreshold    = 5
+	DefaultOpenTimeout         = 30 * time.Second
+	DefaultHalfOpenMaxRequests = 1
+)
+
+var circuitState = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "rpc_circuit_breaker_state",
+		Help: "State of circuit breakers for outbound calls, 0 = closed, 1 = half-open and 2 = open",
+	},
+	[]string{"circuit"},
+)
+
+// CircuitState state of a circuit.
+type CircuitState int
+
+// Circuit states.
+const (
+	CircuitClosed CircuitState = iota
+	CircuitHalfOpen
+	CircuitOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitHalfOpen:
+		return "half-open"
+	case CircuitOpen:
+		return "open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// BreakerConfig configuration of a CircuitBreaker.
+type BreakerConfig struct {
+	// FailureThreshold number of consecutive failures that opens a circuit.
+	FailureThreshold int
+	// OpenTimeout time a circuit stays open before trial requests are let through.
+	OpenTimeout time.Duration
+	// HalfOpenMaxRequests number of concurrent trial requests let through a half-open circuit.
+	HalfOpenMaxRequests int
+	// PerEndpoint keys circuits by endpoint, with query parameters stripped and UUIDs and numeric IDs
+	// in the path replaced, instead of by base URL.
+	PerEndpoint bool
+}
+
+// DefaultBreakerConfig creates a BreakerConfig opening a circuit per base URL after five consecutive failures.
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		FailureThreshold:    DefaultFailureThreshold,
+		OpenTimeout:         DefaultOpenTimeout,
+		HalfOpenMaxRequests: DefaultHalfOpenMaxRequests,
+	}
+}
+
+// CircuitBreaker keeps track of failing downstream services and fails requests to them fast.
+// Transport errors and 5xx responses count as failures. Once the failure threshold is reached
+// a circuit opens, and requests are rejected with 503 - Service Unavailable until the open
+// timeout has passed. The circuit is then half-open, closing again if a trial request succeeds.
+// Results of requests that were let through before a circuit opened are ignored once it has.
+type CircuitBreaker struct {
+	cfg BreakerConfig
+	now func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	trials   int
+}
+
+// NewCircuitBreaker creates a new CircuitBreaker.
+func NewCircuitBreaker(cfg BreakerConfig) *CircuitBreaker {
+	return &CircuitBreaker{
+		cfg:      cfg,
+		now:      time.Now,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+// Wrap decorates an rpc.Client, passing requests through the circuit breaker.
+func (b *CircuitBreaker) Wrap(client rpc.Client) rpc.Client {
+	return &breakerClient{
+		Client:  client,
+		breaker: b,
+	}
+}
+
+// State returns the state of the circuit with the given key.
+func (b *CircuitBreaker) State(key string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[key]
+	if !ok {
+		return CircuitClosed
+	}
+
+	return c.state
+}
+
+type breakerClient struct {
+	rpc.Client
+	breaker *CircuitBreaker
+}
+
+func (c *breakerClient) Do(req *http.Request) (*http.Response, error) {
+	key := c.breaker.key(req)
+	trial, err := c.breaker.allow(key)
+	if err != nil {
+		closeBody(req)
+		return nil, err
+	}
+
+	res, err := c.Client.Do(req)
+	if isCancelled(req, err) {
+		c.breaker.release(key, trial)
+		return res, err
+	}
+
+	c.breaker.record(key, trial, isFailure(res, err))
+	return res, err
+}
+
+var numericRegexp = regexp.MustCompile(`^[0-9]+$`)
+
+func (b *CircuitBreaker) key(req *http.Request) string {
+	if b.cfg.PerEndpoint {
+		return stripNumericIDs(stripQueryAndUUIDs(req.URL.String()))
+	}
+
+	return fmt.Sprintf("%s://%s", req.URL.Scheme, req.URL.Host)
+}
+
+// allow checks if a request may pass through a circuit, moving an open circuit to half-open once the open timeout has passed.
+// Returns whether the request is a trial request through a half-open circuit.
+func (b *CircuitBreaker) allow(key string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.getCircuit(key)
+	if c.state == CircuitOpen && b.now().Sub(c.openedAt) >= b.cfg.OpenTimeout {
+		b.setState(key, c, CircuitHalfOpen)
+	}
+
+	switch c.state {
+	case CircuitOpen:
+		return false, httputil.ServiceUnavailableError(fmt.Errorf("%w: %s", ErrCircuitOpen, key))
+	case CircuitHalfOpen:
+		if c.trials >= b.cfg.HalfOpenMaxRequests {
+			return false, httputil.ServiceUnavailableError(fmt.Errorf("%w: %s is half-open", ErrCircuitOpen, key))
+		}
+		c.trials++
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// record records the result of a request. Only trial requests decide the state of a half-open circuit,
+// while results arriving when a circuit is open, from requests let through before it opened, are ignored.
+func (b *CircuitBreaker) record(key string, trial, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.getCircuit(key)
+	switch c.state {
+	case CircuitOpen:
+		return
+	case CircuitHalfOpen:
+		if !trial {
+			return
+		}
+		if failed {
+			c.openedAt = b.now()
+			b.setState(key, c, CircuitOpen)
+			return
+		}
+		c.failures = 0
+		b.setState(key, c, CircuitClosed)
+	case CircuitClosed:
+		if !failed {
+			c.failures = 0
+			return
+		}
+
+		c.failures++
+		if c.failures >= b.cfg.FailureThreshold {
+			c.openedAt = b.now()
+			b.setState(key, c, CircuitOpen)
+		}
+	}
+}
+
+// release releases the trial slot of a request that was cancelled by its caller, without recording a result.
+func (b *CircuitBreaker) release(key string, trial bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.getCircuit(key)
+	if trial && c.state == CircuitHalfOpen && c.trials > 0 {
+		c.trials--
+	}
+}
+
+func (b *CircuitBreaker) getCircuit(key string) *circuit {
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{state: CircuitClosed}
+		b.circuits[key] = c
+		circuitState.WithLabelValues(key).Set(float64(CircuitClosed))
+	}
+
+	return c
+}
+
+func (b *CircuitBreaker) setState(key string, c *circuit, state CircuitState) {
+	if c.state != state {
+		log.Info("circuit breaker state changed",
+			zap.String("circuit", key),
+			zap.Stringer("from", c.state),
+			zap.Stringer("to", state))
+	}
+
+	c.state = state
+	c.trials = 0
+	circuitState.WithLabelValues(key).Set(float64(state))
+}
+
+// stripNumericIDs replaces numeric path segments of an url, keeping the number of circuits bounded.
+func stripNumericIDs(url string) string {
+	segments := strings.Split(url, "/")
+	for i := 3; i < len(segments); i++ {
+		if numericRegexp.MatchString(segments[i]) {
+			segments[i] = ":id"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// isCancelled checks if a request failed because its caller cancelled it or its context expired,
+// which says nothing about the health of the downstream service.
+func isCancelled(req *http.Request, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	return errors.Is(err, context.Canceled) || req.Context().Err() != nil
+}
+
+func isFailure(res *http.Response, err error) bool {
+	return statusOf(res, err) >= http.StatusInternalServerError
+}
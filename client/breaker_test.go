@@ -0,0 +1,246 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/httputil"
+	"github.com/CzarSimon/httputil/client/rpc"
+	prometheustest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	assert := assert.New(t)
+	downstream := &stubClient{
+		Client: rpc.NewClient(time.Second),
+		err:    httputil.ServiceUnavailablef("service is down"),
+	}
+
+	breaker := NewCircuitBreaker(BreakerConfig{
+		FailureThreshold:    2,
+		OpenTimeout:         time.Minute,
+		HalfOpenMaxRequests: 1,
+	})
+	now := time.Now()
+	breaker.now = func() time.Time {
+		return now
+	}
+
+	c := &Client{
+		BaseURL:   "http://breaker-service:8080",
+		RPCClient: breaker.Wrap(downstream),
+	}
+	key := "http://breaker-service:8080"
+	ctx := context.Background()
+
+	err := c.Get(ctx, "/test", nil)
+	assert.False(errors.Is(err, ErrCircuitOpen))
+	assert.Equal(CircuitClosed, breaker.State(key))
+
+	err = c.Get(ctx, "/other", nil)
+	assert.False(errors.Is(err, ErrCircuitOpen))
+	assert.Equal(CircuitOpen, breaker.State(key))
+	assert.Equal(2.0, prometheustest.ToFloat64(circuitState.WithLabelValues(key)))
+
+	err = c.Get(ctx, "/test", nil)
+	assert.True(errors.Is(err, ErrCircuitOpen))
+	assert.True(rpc.HasStatus(err, http.StatusServiceUnavailable))
+	assert.Equal(2, downstream.calls)
+
+	now = now.Add(time.Minute)
+	err = c.Get(ctx, "/test", nil)
+	assert.False(errors.Is(err, ErrCircuitOpen))
+	assert.Equal(3, downstream.calls)
+	assert.Equal(CircuitOpen, breaker.State(key))
+
+	now = now.Add(time.Minute)
+	downstream.err = nil
+	err = c.Get(ctx, "/test", nil)
+	assert.NoError(err)
+	assert.Equal(CircuitClosed, breaker.State(key))
+	assert.Equal(0.0, prometheustest.ToFloat64(circuitState.WithLabelValues(key)))
+
+	downstream.err = httputil.NotFoundf("not found")
+	for i := 0; i < 3; i++ {
+		err = c.Get(ctx, "/test", nil)
+		assert.True(rpc.HasStatus(err, http.StatusNotFound))
+	}
+	assert.Equal(CircuitClosed, breaker.State(key))
+}
+
+func TestCircuitBreakerPerEndpoint(t *testing.T) {
+	assert := assert.New(t)
+	downstream := &stubClient{
+		Client: rpc.NewClient(time.Second),
+		err:    httputil.ServiceUnavailablef("service is down"),
+	}
+
+	cfg := DefaultBreakerConfig()
+	cfg.FailureThreshold = 1
+	cfg.PerEndpoint = true
+	breaker := NewCircuitBreaker(cfg)
+	c := &Client{
+		BaseURL:   "http://endpoint-service:8080",
+		RPCClient: breaker.Wrap(downstream),
+		Retry:     DefaultRetryPolicy(),
+	}
+
+	err := c.Get(context.Background(), "/v1/things/1d2ac3f8-43f5-4b8e-9a1c-2e4f1e2d3c4b?expand=true", nil)
+	assert.True(errors.Is(err, ErrCircuitOpen))
+	assert.Equal(1, downstream.calls)
+	assert.Equal(CircuitOpen, breaker.State("http://endpoint-service:8080/v1/things/:id"))
+	assert.Equal(CircuitClosed, breaker.State("http://endpoint-service:8080/v1/other"))
+
+	err = c.Get(context.Background(), "/v1/users/123/orders/42", nil)
+	assert.True(errors.Is(err, ErrCircuitOpen))
+	assert.Equal(CircuitOpen, breaker.State("http://endpoint-service:8080/v1/users/:id/orders/:id"))
+	err = c.Get(context.Background(), "/v1/users/456/orders/7", nil)
+	assert.True(errors.Is(err, ErrCircuitOpen))
+	assert.Equal(2, downstream.calls)
+	assert.Equal("http://svc:8080/v1/:id/:id/items", stripNumericIDs("http://svc:8080/v1/12/34/items"))
+}
+
+func TestCircuitBreakerIgnoresStaleResults(t *testing.T) {
+	assert := assert.New(t)
+	breaker := NewCircuitBreaker(BreakerConfig{
+		FailureThreshold:    1,
+		OpenTimeout:         time.Minute,
+		HalfOpenMaxRequests: 1,
+	})
+	now := time.Now()
+	breaker.now = func() time.Time {
+		return now
+	}
+	key := "http://stale-service:8080"
+
+	slowTrial, err := breaker.allow(key)
+	assert.NoError(err)
+	failingTrial, err := breaker.allow(key)
+	assert.NoError(err)
+	breaker.record(key, failingTrial, true)
+	assert.Equal(CircuitOpen, breaker.State(key))
+
+	breaker.record(key, slowTrial, false)
+	assert.Equal(CircuitOpen, breaker.State(key))
+
+	now = now.Add(time.Minute)
+	trial, err := breaker.allow(key)
+	assert.NoError(err)
+	assert.True(trial)
+	assert.Equal(CircuitHalfOpen, breaker.State(key))
+
+	breaker.record(key, false, false)
+	assert.Equal(CircuitHalfOpen, breaker.State(key))
+
+	breaker.record(key, trial, false)
+	assert.Equal(CircuitClosed, breaker.State(key))
+}
+
+func TestCircuitBreakerClosesRejectedBodies(t *testing.T) {
+	assert := assert.New(t)
+	breaker := NewCircuitBreaker(BreakerConfig{
+		FailureThreshold: 1,
+		OpenTimeout:      time.Minute,
+	})
+	downstream := &stubClient{
+		Client: rpc.NewClient(time.Second),
+		err:    httputil.ServiceUnavailablef("service is down"),
+	}
+	c := &Client{
+		BaseURL:   "http://rejecting-service:8080",
+		RPCClient: breaker.Wrap(downstream),
+	}
+	ctx := context.Background()
+	err := c.Get(ctx, "/test", nil)
+	assert.False(errors.Is(err, ErrCircuitOpen))
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		form := rpc.MultipartForm{
+			Files: []rpc.MultipartFile{
+				{Field: "file", Filename: "data.txt", Content: io.MultiReader(strings.NewReader("data"))},
+			},
+		}
+		err = c.Post(ctx, "/upload", form, nil)
+		assert.True(errors.Is(err, ErrCircuitOpen))
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(runtime.NumGoroutine() <= before, "multipart writers leaked")
+}
+
+func TestCircuitBreakerIgnoresCancelledRequests(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	breaker := NewCircuitBreaker(BreakerConfig{
+		FailureThreshold:    2,
+		OpenTimeout:         time.Minute,
+		HalfOpenMaxRequests: 1,
+	})
+	now := time.Now()
+	breaker.now = func() time.Time {
+		return now
+	}
+	c := &Client{
+		BaseURL:   server.URL,
+		RPCClient: breaker.Wrap(rpc.NewClient(time.Second)),
+	}
+
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		err := c.Get(ctx, "/test", nil)
+		assert.Error(err)
+
+		ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+		err = c.Get(ctx, "/test", nil)
+		cancel()
+		assert.Error(err)
+	}
+	assert.Equal(CircuitClosed, breaker.State(server.URL))
+
+	breaker.record(server.URL, false, true)
+	breaker.record(server.URL, false, true)
+	assert.Equal(CircuitOpen, breaker.State(server.URL))
+
+	now = now.Add(time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	err := c.Get(ctx, "/test", nil)
+	cancel()
+	assert.False(errors.Is(err, ErrCircuitOpen))
+	assert.Equal(CircuitHalfOpen, breaker.State(server.URL))
+
+	trial, err := breaker.allow(server.URL)
+	assert.NoError(err)
+	assert.True(trial)
+}
+
+type stubClient struct {
+	rpc.Client
+	err   error
+	calls int
+}
+
+func (c *stubClient) Do(req *http.Request) (*http.Response, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Header: http.Header{}}, nil
+}
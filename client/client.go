@@ -2,11 +2,17 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/CzarSimon/httputil"
@@ -14,6 +20,7 @@ import (
 	"github.com/CzarSimon/httputil/jwt"
 	"github.com/CzarSimon/httputil/logger"
 	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"go.uber.org/zap"
@@ -28,7 +35,7 @@ var (
 			Name: "rpc_requests_total",
 			Help: "The total number of remote procedure calls",
 		},
-		[]string{"endpoint", "method", "status"},
+		[]string{"endpoint", "method", "status", "attempt"},
 	)
 	rpcLatency = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -47,99 +54,425 @@ type Client struct {
 	Role      string
 	UserAgent string
 	RPCClient rpc.Client
+	// Audience of the tokens issued by the client. Defaults to the host name of the BaseURL.
+	Audience string
+	// TokenLifetime lifetime of the cached tokens issued by the client. Defaults to DefaultTokenLifetime.
+	TokenLifetime time.Duration
+	// TokenSource overrides the tokens issued by the client, e.g. with a static token.
+	TokenSource TokenSource
+	// Retry policy of the client, by default a single attempt is made.
+	Retry RetryPolicy
+	// OnBehalfOf makes calls with a principal on the context, see WithPrincipal, authenticate with a token
+	// issued for the client that carries the ID of the end user as actor, instead of forwarding a token.
+	OnBehalfOf bool
+
+	// tokens holds the *tokenCache of the client, created on first use and shared by copies of the client.
+	tokens atomic.Value
+}
+
+// tokenKey configuration of a client that the tokens it issues depend on.
+type tokenKey struct {
+	issuer   jwt.Issuer
+	user     string
+	role     string
+	audience string
+	lifetime time.Duration
+}
+
+// clientTokens token state of a client configuration.
+type clientTokens struct {
+	source     TokenSource
+	onBehalfOf *onBehalfOfTokens
 }
 
+// tokenCache token state of a client per configuration, held behind a pointer so that the client can be copied.
+// Copies of a client share the cache, but only the tokens issued for their own configuration.
+type tokenCache struct {
+	mu     sync.Mutex
+	tokens map[tokenKey]*clientTokens
+}
+
+// tokensMu guards the creation of the token caches of clients.
+var tokensMu sync.Mutex
+
 // Get performs a GET request.
-func (c *Client) Get(ctx context.Context, path string, v interface{}) error {
-	return c.request(ctx, http.MethodGet, path, nil, v)
+func (c *Client) Get(ctx context.Context, path string, v interface{}, opts ...RequestOption) error {
+	return c.request(ctx, http.MethodGet, path, nil, v, opts)
+}
+
+// Head performs a HEAD request, returning the response to access its status and headers.
+func (c *Client) Head(ctx context.Context, path string, opts ...RequestOption) (*http.Response, error) {
+	res, err := c.Do(ctx, http.MethodHead, path, nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Body.Close()
+	return res, nil
 }
 
 // Put performs a PUT request.
-func (c *Client) Put(ctx context.Context, path string, body, v interface{}) error {
-	return c.request(ctx, http.MethodPut, path, body, v)
+func (c *Client) Put(ctx context.Context, path string, body, v interface{}, opts ...RequestOption) error {
+	return c.request(ctx, http.MethodPut, path, body, v, opts)
 }
 
 // Post performs a POST request.
-func (c *Client) Post(ctx context.Context, path string, body, v interface{}) error {
-	return c.request(ctx, http.MethodPost, path, body, v)
+func (c *Client) Post(ctx context.Context, path string, body, v interface{}, opts ...RequestOption) error {
+	return c.request(ctx, http.MethodPost, path, body, v, opts)
+}
+
+// Patch performs a PATCH request.
+func (c *Client) Patch(ctx context.Context, path string, body, v interface{}, opts ...RequestOption) error {
+	return c.request(ctx, http.MethodPatch, path, body, v, opts)
 }
 
 // Delete performs a DELETE request.
-func (c *Client) Delete(ctx context.Context, path string, v interface{}) error {
-	return c.request(ctx, http.MethodDelete, path, nil, v)
+func (c *Client) Delete(ctx context.Context, path string, v interface{}, opts ...RequestOption) error {
+	return c.request(ctx, http.MethodDelete, path, nil, v, opts)
+}
+
+// Do performs a request with any method and returns the raw response. The caller must close the response body.
+func (c *Client) Do(ctx context.Context, method, path string, body interface{}, opts ...RequestOption) (*http.Response, error) {
+	o := newRequestOptions(opts)
+	ctx, cancel := o.context(ctx)
+	res, err := c.do(ctx, method, path, body, o)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+// Stream performs a request and hands the response to fn to read the body as a stream, e.g. with rpc.NewJSONStream.
+// The response body is closed once fn returns. Request bodies given as a rpc.Stream or io.Reader are streamed
+// without being buffered, and are never retried since they can only be read once.
+func (c *Client) Stream(ctx context.Context, method, path string, body interface{}, fn func(*http.Response) error, opts ...RequestOption) error {
+	res, err := c.Do(ctx, method, path, body, opts...)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return fn(res)
+}
+
+// Download performs a GET request and streams the response body into w, returning the number of bytes written.
+func (c *Client) Download(ctx context.Context, path string, w io.Writer, opts ...RequestOption) (int64, error) {
+	var n int64
+	err := c.Stream(ctx, http.MethodGet, path, nil, func(res *http.Response) error {
+		var err error
+		n, err = io.Copy(w, res.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response body\n%w", err)
+		}
+
+		return nil
+	}, opts...)
+
+	return n, err
+}
+
+func (c *Client) request(ctx context.Context, method, path string, body, v interface{}, opts []RequestOption) error {
+	o := newRequestOptions(opts)
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	res, err := c.do(ctx, method, path, body, o)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if o.response != nil {
+		defer func() {
+			*o.response = *res
+		}()
+	}
+
+	if v == nil || res.StatusCode == http.StatusNotModified {
+		return nil
+	}
+
+	return rpc.Decode(res, v)
+}
+
+// do performs a request, retrying failed attempts according to the retry policy of the client.
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, o *requestOptions) (*http.Response, error) {
+	err := o.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	maxAttempts := c.Retry.maxAttempts(ctx, method)
+	if rpc.IsStream(body) {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			delay, ok := c.Retry.backoff(attempt-1, err)
+			if !ok {
+				return nil, err
+			}
+
+			sleepErr := sleep(ctx, delay)
+			if sleepErr != nil {
+				return nil, err
+			}
+		}
+
+		var res *http.Response
+		var retryable bool
+		res, retryable, err = c.attempt(ctx, method, path, body, o, attempt)
+		if err == nil {
+			return res, nil
+		}
+		if !retryable || !c.Retry.shouldRetry(err) {
+			return nil, err
+		}
+	}
+
+	return nil, err
 }
 
-func (c *Client) request(ctx context.Context, method, path string, body, v interface{}) error {
-	req, err := c.RPCClient.CreateRequest(method, c.BaseURL+path, body)
+// attempt performs a single attempt of a request as a child span of the span on the context, if any.
+// Returns whether a failure happened while performing the request, and could be retried.
+func (c *Client) attempt(ctx context.Context, method, path string, body interface{}, o *requestOptions, attempt int) (*http.Response, bool, error) {
+	req, err := c.RPCClient.CreateRequest(method, o.url(c.BaseURL+path), body)
 	if err != nil {
-		return fmt.Errorf("failed to create request\n%w", err)
+		return nil, false, fmt.Errorf("failed to create request\n%w", err)
 	}
+	req = req.WithContext(ctx)
 
-	c.addToken(req)
-	injectSpan(ctx, req)
+	err = c.addToken(ctx, req, o)
+	if err != nil {
+		closeBody(req)
+		return nil, false, err
+	}
+
+	key, ok := IdempotencyKeyFromContext(ctx)
+	if ok {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	for name, values := range o.header {
+		req.Header[name] = values
+	}
+
+	span := c.startSpan(ctx, method, path, attempt)
+	if span != nil {
+		defer span.Finish()
+		injectSpan(span, req)
+	}
 
 	timer := createTimer()
 	res, err := c.RPCClient.Do(req)
+	status := statusOf(res, err)
+	c.recordMetrics(timer, path, method, status, attempt)
+	if span != nil {
+		ext.HTTPStatusCode.Set(span, uint16(status))
+		if err != nil {
+			ext.Error.Set(span, true)
+		}
+	}
+
 	if err != nil {
-		c.recordMetricsOnError(timer, path, method, res)
-		return err
+		return nil, true, err
 	}
 
-	defer c.recordMetrics(timer, path, method, res.StatusCode)
-	if v == nil {
+	return res, false, nil
+}
+
+// closeBody closes the body of a request that is never sent, releasing any goroutine streaming it.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+func (c *Client) startSpan(ctx context.Context, method, path string, attempt int) opentracing.Span {
+	parent := opentracing.SpanFromContext(ctx)
+	if parent == nil {
 		return nil
 	}
 
-	return rpc.DecodeJSON(res, v)
+	endpoint := stripQueryAndUUIDs(c.BaseURL + path)
+	span := opentracing.StartSpan(fmt.Sprintf("%s %s", method, endpoint), opentracing.ChildOf(parent.Context()))
+	ext.SpanKindRPCClient.Set(span)
+	ext.HTTPMethod.Set(span, method)
+	ext.HTTPUrl.Set(span, endpoint)
+	span.SetTag("attempt", attempt)
+	return span
 }
 
-func (c *Client) recordMetricsOnError(timer calcDuration, path, method string, res *http.Response) {
-	statusCode := http.StatusServiceUnavailable
-	if res != nil {
-		statusCode = res.StatusCode
+func statusOf(res *http.Response, err error) int {
+	var httpErr *httputil.Error
+	if errors.As(err, &httpErr) {
+		return httpErr.Status
+	}
+	if err != nil || res == nil {
+		return http.StatusServiceUnavailable
 	}
 
-	c.recordMetrics(timer, path, method, statusCode)
+	return res.StatusCode
 }
 
-func (c *Client) recordMetrics(timer calcDuration, path, method string, statusCode int) {
+func (c *Client) recordMetrics(timer calcDuration, path, method string, statusCode, attempt int) {
 	latency := timer()
 	endpoint := stripQueryAndUUIDs(c.BaseURL + path)
 	status := strconv.Itoa(statusCode)
 
-	rpcsTotal.WithLabelValues(endpoint, method, status).Inc()
+	rpcsTotal.WithLabelValues(endpoint, method, status, strconv.Itoa(attempt)).Inc()
 	rpcLatency.WithLabelValues(endpoint, method, status).Observe(latency)
 }
 
-func (c *Client) addToken(req *http.Request) {
-	token, err := c.Issuer.Issue(jwt.User{
+// addToken authenticates a request with the token of the request options, an on-behalf-of token or the
+// token set on the context, if any, otherwise with a token from the token source of the client.
+func (c *Client) addToken(ctx context.Context, req *http.Request, o *requestOptions) error {
+	if o.noAuth {
+		return nil
+	}
+	if o.token != "" {
+		req.Header.Set("Authorization", "Bearer "+o.token)
+		return nil
+	}
+
+	token, ok, err := c.onBehalfOfToken(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to issue on-behalf-of token\n%w", err)
+	}
+	if !ok {
+		token, ok = TokenFromContext(ctx)
+	}
+	if !ok {
+		tokens := c.tokenSource()
+		if tokens == nil {
+			return nil
+		}
+
+		token, err = tokens.Token(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get auth token\n%w", err)
+		}
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (c *Client) tokenSource() TokenSource {
+	if c.TokenSource != nil {
+		return c.TokenSource
+	}
+
+	return c.clientTokens().source
+}
+
+// clientTokens returns the token state of the current configuration of the client, creating it on first use.
+// The state is not cached for issuers that cannot be compared, which issue a new token for every request.
+func (c *Client) clientTokens() *clientTokens {
+	if c.Issuer != nil && !reflect.TypeOf(c.Issuer).Comparable() {
+		return c.newClientTokens()
+	}
+
+	key := tokenKey{
+		issuer:   c.Issuer,
+		user:     c.UserAgent,
+		role:     c.Role,
+		audience: c.audience(),
+		lifetime: c.tokenLifetime(),
+	}
+
+	cache := c.tokenCache()
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	tokens, ok := cache.tokens[key]
+	if !ok {
+		tokens = c.newClientTokens()
+		cache.tokens[key] = tokens
+	}
+
+	return tokens
+}
+
+func (c *Client) newClientTokens() *clientTokens {
+	tokens := &clientTokens{
+		onBehalfOf: newOnBehalfOfTokens(),
+	}
+	if c.Issuer != nil {
+		tokens.source = NewIssuingTokenSource(c.Issuer, c.serviceUser(), c.tokenLifetime(), c.audience())
+	}
+
+	return tokens
+}
+
+// tokenCache returns the token cache of the client, creating it on first use.
+func (c *Client) tokenCache() *tokenCache {
+	cache, ok := c.tokens.Load().(*tokenCache)
+	if ok {
+		return cache
+	}
+
+	tokensMu.Lock()
+	defer tokensMu.Unlock()
+	cache, ok = c.tokens.Load().(*tokenCache)
+	if ok {
+		return cache
+	}
+
+	cache = &tokenCache{
+		tokens: make(map[tokenKey]*clientTokens),
+	}
+	c.tokens.Store(cache)
+	return cache
+}
+
+// serviceUser returns the user the client authenticates as.
+func (c *Client) serviceUser() jwt.User {
+	return jwt.User{
 		ID:    c.UserAgent,
 		Roles: []string{c.Role},
-	}, 24*time.Hour)
+	}
+}
+
+func (c *Client) tokenLifetime() time.Duration {
+	if c.TokenLifetime == 0 {
+		return DefaultTokenLifetime
+	}
+
+	return c.TokenLifetime
+}
+
+// audience returns the configured audience or the host name of the target service.
+func (c *Client) audience() string {
+	if c.Audience != "" {
+		return c.Audience
+	}
+
+	u, err := url.Parse(c.BaseURL)
 	if err != nil {
-		log.Warn("failed to create auth token", zap.Error(err))
+		log.Warn("failed to parse base url", zap.String("baseUrl", c.BaseURL), zap.Error(err))
+		return ""
 	}
 
-	req.Header.Add("Authorization", "Bearer "+token)
+	return u.Hostname()
 }
 
-func injectSpan(ctx context.Context, req *http.Request) {
-	span := opentracing.SpanFromContext(ctx)
-	if span != nil {
-		reqID := span.BaggageItem(httputil.RequestIDHeader)
-		if reqID != "" {
-			req.Header.Set(httputil.RequestIDHeader, reqID)
-		}
+func injectSpan(span opentracing.Span, req *http.Request) {
+	reqID := span.BaggageItem(httputil.RequestIDHeader)
+	if reqID != "" {
+		req.Header.Set(httputil.RequestIDHeader, reqID)
+	}
 
-		err := opentracing.GlobalTracer().Inject(
-			span.Context(),
-			opentracing.HTTPHeaders,
-			opentracing.HTTPHeadersCarrier(req.Header),
-		)
+	err := opentracing.GlobalTracer().Inject(
+		span.Context(),
+		opentracing.HTTPHeaders,
+		opentracing.HTTPHeadersCarrier(req.Header),
+	)
 
-		if err != nil {
-			log.Warn("failed to inject span context into client http request", zap.Error(err))
-		}
+	if err != nil {
+		log.Warn("failed to inject span context into client http request", zap.Error(err))
 	}
 }
 
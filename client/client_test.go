@@ -82,3 +82,32 @@ func TestStripQueryAndUUIDs(t *testing.T) {
 		assert.Equal(test.ouput, actual, fmt.Sprintf("%d - stripQueryAndUUIDs failed", i+1))
 	}
 }
+
+func TestAudience(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		client Client
+		want   string
+	}{
+		{
+			client: Client{BaseURL: "http://user-service:8080"},
+			want:   "user-service",
+		},
+		{
+			client: Client{BaseURL: "https://api.example.com/v1"},
+			want:   "api.example.com",
+		},
+		{
+			client: Client{BaseURL: "http://user-service:8080", Audience: "users"},
+			want:   "users",
+		},
+		{
+			client: Client{},
+			want:   "",
+		},
+	}
+
+	for i, test := range tests {
+		assert.Equal(test.want, test.client.audience(), fmt.Sprintf("%d - audience failed", i+1))
+	}
+}
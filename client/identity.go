@@ -0,0 +1,119 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CzarSimon/httputil"
+	"github.com/CzarSimon/httputil/jwt"
+	"github.com/gin-gonic/gin"
+)
+
+type principalContextKey struct{}
+
+// WithPrincipal returns a context carrying the principal that triggered an outbound call.
+// Clients with OnBehalfOf enabled authenticate such calls with an on-behalf-of token.
+func WithPrincipal(ctx context.Context, principal jwt.User) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal set on a context with WithPrincipal.
+func PrincipalFromContext(ctx context.Context) (jwt.User, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(jwt.User)
+	return principal, ok
+}
+
+// ForwardCaller returns the context of an inbound request carrying its principal and bearer token,
+// so that outbound calls made with it propagate the identity of the caller.
+func ForwardCaller(c *gin.Context) context.Context {
+	ctx := c.Request.Context()
+	principal, ok := httputil.GetPrincipal(c)
+	if ok && !principal.IsAnonymous() {
+		ctx = WithPrincipal(ctx, principal)
+	}
+
+	header := c.GetHeader("Authorization")
+	if strings.HasPrefix(header, "Bearer ") {
+		ctx = WithToken(ctx, strings.TrimPrefix(header, "Bearer "))
+	}
+
+	return ctx
+}
+
+// onBehalfOfToken returns a token for the client acting on behalf of the principal on the context.
+// Tokens are cached per actor until close to expiry, like the service tokens of the client.
+func (c *Client) onBehalfOfToken(ctx context.Context) (string, bool, error) {
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok || !c.OnBehalfOf || c.Issuer == nil {
+		return "", false, nil
+	}
+
+	user := c.serviceUser().OnBehalfOf(principal)
+	lifetime := c.tokenLifetime()
+	tokens := c.clientTokens().onBehalfOf.get(user.ActorID, lifetime, func() *IssuingTokenSource {
+		return NewIssuingTokenSource(c.Issuer, user, lifetime, c.audience())
+	})
+
+	token, err := tokens.Token(ctx)
+	if err != nil {
+		return "", false, err
+	}
+
+	return token, true, nil
+}
+
+// maxOnBehalfOfTokens number of cached on-behalf-of token sources above which unused ones are evicted.
+const maxOnBehalfOfTokens = 1024
+
+// onBehalfOfTokens cache of token sources issuing on-behalf-of tokens, keyed by actor.
+// The audience and lifetime of the tokens are fixed per client configuration.
+type onBehalfOfTokens struct {
+	mu      sync.Mutex
+	sources map[string]*onBehalfOfSource
+}
+
+type onBehalfOfSource struct {
+	tokens   *IssuingTokenSource
+	lastUsed time.Time
+}
+
+func newOnBehalfOfTokens() *onBehalfOfTokens {
+	return &onBehalfOfTokens{
+		sources: make(map[string]*onBehalfOfSource),
+	}
+}
+
+// get returns the token source of an actor, creating it if missing.
+func (t *onBehalfOfTokens) get(actorID string, lifetime time.Duration, create func() *IssuingTokenSource) *IssuingTokenSource {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	source, ok := t.sources[actorID]
+	if !ok {
+		if len(t.sources) >= maxOnBehalfOfTokens {
+			t.evict(now, lifetime)
+		}
+		source = &onBehalfOfSource{tokens: create()}
+		t.sources[actorID] = source
+	}
+
+	source.lastUsed = now
+	return source.tokens
+}
+
+// evict removes sources not used within a token lifetime, whose tokens have expired,
+// or all sources if every one of them is in use.
+func (t *onBehalfOfTokens) evict(now time.Time, lifetime time.Duration) {
+	for actorID, source := range t.sources {
+		if now.Sub(source.lastUsed) > lifetime {
+			delete(t.sources, actorID)
+		}
+	}
+
+	if len(t.sources) >= maxOnBehalfOfTokens {
+		t.sources = make(map[string]*onBehalfOfSource)
+	}
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/httputil"
+	"github.com/CzarSimon/httputil/client/rpc"
+	"github.com/CzarSimon/httputil/jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForwardCallerAndOnBehalfOf(t *testing.T) {
+	assert := assert.New(t)
+	creds := jwt.Credentials{
+		Issuer: "client-test",
+		Secret: "very-secret-secret",
+	}
+	issuer := jwt.NewIssuer(creds)
+	verifier := jwt.NewVerifier(creds, time.Minute)
+
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("Authorization")[len("Bearer "):]
+		user, err := verifier.Verify(token)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"userId":  user.ID,
+			"actorId": user.ActorID,
+		})
+	}))
+	defer downstream.Close()
+
+	forwarding := &Client{
+		Issuer:    issuer,
+		BaseURL:   downstream.URL,
+		Role:      jwt.SystemRole,
+		UserAgent: "forwarding-service",
+		RPCClient: rpc.NewClient(time.Second),
+	}
+	onBehalfOf := &Client{
+		Issuer:     issuer,
+		BaseURL:    downstream.URL,
+		Role:       jwt.SystemRole,
+		UserAgent:  "acting-service",
+		RPCClient:  rpc.NewClient(time.Second),
+		OnBehalfOf: true,
+	}
+
+	rbac := httputil.NewRBAC(creds)
+	r := httputil.NewRouter("client-test", func() error {
+		return nil
+	})
+	r.GET("/forward", rbac.Secure(jwt.UserRole), proxyTo(forwarding))
+	r.GET("/on-behalf-of", rbac.Secure(jwt.UserRole), proxyTo(onBehalfOf))
+	r.GET("/anonymous", rbac.Authenticate(), proxyTo(onBehalfOf))
+
+	token, err := issuer.Issue(jwt.User{ID: "end-user-id", Roles: []string{jwt.UserRole}}, time.Hour)
+	assert.NoError(err)
+
+	tests := []struct {
+		path    string
+		token   string
+		userID  string
+		actorID string
+	}{
+		{path: "/forward", token: token, userID: "end-user-id", actorID: ""},
+		{path: "/on-behalf-of", token: token, userID: "acting-service", actorID: "end-user-id"},
+		{path: "/anonymous", token: "", userID: "acting-service", actorID: ""},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if test.token != "" {
+			req.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		res := httptest.NewRecorder()
+		r.ServeHTTP(res, req)
+		assert.Equal(http.StatusOK, res.Code, test.path)
+
+		var body map[string]string
+		err = json.NewDecoder(res.Body).Decode(&body)
+		assert.NoError(err, test.path)
+		assert.Equal(test.userID, body["userId"], test.path)
+		assert.Equal(test.actorID, body["actorId"], test.path)
+	}
+
+	_, ok := PrincipalFromContext(context.Background())
+	assert.False(ok)
+}
+
+func proxyTo(client *Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body map[string]string
+		err := client.Get(ForwardCaller(c), "/", &body)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, body)
+	}
+}
+
+func TestOnBehalfOfTokenCaching(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"authorization": r.Header.Get("Authorization")})
+	}))
+	defer server.Close()
+
+	issuer := &countingIssuer{}
+	c := &Client{
+		Issuer:     issuer,
+		BaseURL:    server.URL,
+		Role:       jwt.SystemRole,
+		UserAgent:  "client-test",
+		RPCClient:  rpc.NewClient(time.Second),
+		OnBehalfOf: true,
+	}
+
+	var body map[string]string
+	first := WithPrincipal(context.Background(), jwt.User{ID: "user-1"})
+	for i := 0; i < 3; i++ {
+		err := c.Get(first, "/test", &body)
+		assert.NoError(err)
+		assert.Equal("Bearer token-1", body["authorization"])
+	}
+	assert.Equal(1, issuer.count())
+
+	second := WithPrincipal(context.Background(), jwt.User{ID: "user-2"})
+	err := c.Get(second, "/test", &body)
+	assert.NoError(err)
+	assert.Equal("Bearer token-2", body["authorization"])
+
+	err = c.Get(first, "/test", &body)
+	assert.NoError(err)
+	assert.Equal("Bearer token-1", body["authorization"])
+	assert.Equal(2, issuer.count())
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrReservedHeader is returned when a request option sets a header managed by the client.
+var ErrReservedHeader = errors.New("header is managed by the client")
+
+// reservedHeaders headers set by the client that cannot be overridden with WithHeader.
+var reservedHeaders = []string{"Authorization", IdempotencyKeyHeader}
+
+// RequestOption option to configure a single request.
+type RequestOption func(*requestOptions)
+
+type requestOptions struct {
+	header   http.Header
+	query    url.Values
+	timeout  time.Duration
+	token    string
+	noAuth   bool
+	response *http.Response
+}
+
+// WithHeader sets a header on the request. The Authorization and Idempotency-Key headers are managed
+// by the client and rejected with ErrReservedHeader, use WithAuthToken and WithIdempotencyKey instead.
+func WithHeader(key, value string) RequestOption {
+	return func(o *requestOptions) {
+		o.header.Set(key, value)
+	}
+}
+
+// WithQuery adds query parameter values to the request, encoded and appended to any query already in the path.
+func WithQuery(key string, values ...string) RequestOption {
+	return func(o *requestOptions) {
+		for _, value := range values {
+			o.query.Add(key, value)
+		}
+	}
+}
+
+// WithTimeout bounds the duration of the request, including retries.
+func WithTimeout(timeout time.Duration) RequestOption {
+	return func(o *requestOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithAuthToken authenticates the request with the supplied token instead of the token of the client.
+func WithAuthToken(token string) RequestOption {
+	return func(o *requestOptions) {
+		o.token = token
+	}
+}
+
+// WithoutAuth sends the request without an Authorization header.
+func WithoutAuth() RequestOption {
+	return func(o *requestOptions) {
+		o.noAuth = true
+	}
+}
+
+// WithResponse copies the response into res once the request has completed, giving access to
+// its status and headers, e.g. Location or ETag. The body has been consumed and closed by then.
+func WithResponse(res *http.Response) RequestOption {
+	return func(o *requestOptions) {
+		o.response = res
+	}
+}
+
+func newRequestOptions(opts []RequestOption) *requestOptions {
+	o := &requestOptions{
+		header: http.Header{},
+		query:  url.Values{},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// validate checks that the options do not override headers managed by the client.
+func (o *requestOptions) validate() error {
+	for _, name := range reservedHeaders {
+		_, ok := o.header[http.CanonicalHeaderKey(name)]
+		if ok {
+			return fmt.Errorf("%w: %s", ErrReservedHeader, name)
+		}
+	}
+
+	return nil
+}
+
+// url appends the encoded query parameters to a url.
+func (o *requestOptions) url(rawURL string) string {
+	if len(o.query) == 0 {
+		return rawURL
+	}
+
+	separator := "?"
+	if strings.Contains(rawURL, "?") {
+		separator = "&"
+	}
+
+	return rawURL + separator + o.query.Encode()
+}
+
+// context applies the request timeout to a context.
+func (o *requestOptions) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, o.timeout)
+}
+
+// cancelOnClose response body releasing the context of the request once closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/httputil/client/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestOptions(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Location", "/things/1")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if r.Method == http.MethodHead {
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"method":        r.Method,
+			"query":         r.URL.RawQuery,
+			"authorization": r.Header.Get("Authorization"),
+			"ifMatch":       r.Header.Get("If-Match"),
+		})
+	}))
+	defer server.Close()
+
+	c := &Client{
+		BaseURL:     server.URL,
+		RPCClient:   rpc.NewClient(time.Second),
+		TokenSource: StaticToken("client-token"),
+	}
+	ctx := context.Background()
+
+	var body map[string]string
+	var res http.Response
+	err := c.Patch(ctx, "/things/1?expand=true", map[string]string{"name": "new"}, &body,
+		WithHeader("If-Match", `"v0"`),
+		WithQuery("tag", "a&b", "c d"),
+		WithResponse(&res),
+	)
+	assert.NoError(err)
+	assert.Equal(http.MethodPatch, body["method"])
+	assert.Equal("expand=true&tag=a%26b&tag=c+d", body["query"])
+	assert.Equal(`"v0"`, body["ifMatch"])
+	assert.Equal("Bearer client-token", body["authorization"])
+	assert.Equal(http.StatusCreated, res.StatusCode)
+	assert.Equal("/things/1", res.Header.Get("Location"))
+
+	err = c.Get(ctx, "/things/1", &body, WithAuthToken("other-token"))
+	assert.NoError(err)
+	assert.Equal("Bearer other-token", body["authorization"])
+
+	err = c.Get(ctx, "/things/1", &body, WithoutAuth())
+	assert.NoError(err)
+	assert.Equal("", body["authorization"])
+
+	head, err := c.Head(ctx, "/things/1")
+	assert.NoError(err)
+	assert.Equal(`"v1"`, head.Header.Get("ETag"))
+
+	raw, err := c.Do(ctx, http.MethodPost, "/things", map[string]string{"name": "thing"}, WithTimeout(time.Second))
+	assert.NoError(err)
+	assert.Equal(http.StatusCreated, raw.StatusCode)
+	data, err := ioutil.ReadAll(raw.Body)
+	assert.NoError(err)
+	assert.Contains(string(data), `"method":"POST"`)
+	assert.NoError(raw.Body.Close())
+
+	err = c.Get(ctx, "/slow", nil, WithTimeout(50*time.Millisecond))
+	assert.True(errors.Is(err, context.DeadlineExceeded), err)
+
+	raw, err = c.Do(ctx, http.MethodGet, "/things/1", nil, WithHeader("If-None-Match", `"v1"`))
+	assert.NoError(err)
+	assert.Equal(http.StatusNotModified, raw.StatusCode)
+	assert.NoError(raw.Body.Close())
+
+	body = nil
+	err = c.Get(ctx, "/things/1", &body, WithHeader("If-None-Match", `"v1"`), WithResponse(&res))
+	assert.NoError(err)
+	assert.Nil(body)
+	assert.Equal(http.StatusNotModified, res.StatusCode)
+
+	err = c.Get(ctx, "/things/1", &body, WithHeader("authorization", "Bearer forged"))
+	assert.True(errors.Is(err, ErrReservedHeader), err)
+	err = c.Post(ctx, "/things", nil, nil, WithHeader(IdempotencyKeyHeader, "key-1"))
+	assert.True(errors.Is(err, ErrReservedHeader), err)
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/CzarSimon/httputil"
+	"github.com/CzarSimon/httputil/client/rpc"
+)
+
+// IdempotencyKeyHeader header carrying the idempotency key of a request.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Default retry policy settings.
+const (
+	DefaultMaxAttempts    = 3
+	DefaultInitialBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff     = 2 * time.Second
+)
+
+type idempotencyKeyContextKey struct{}
+
+// RetryPolicy describes how failed requests are retried. Only idempotent methods are retried,
+// unless the request carries an idempotency key, see WithIdempotencyKey. Transport errors such as
+// timeouts, refused and reset connections are retried along with responses with a retryable status,
+// while other transport errors, e.g. DNS or TLS failures, and requests rejected by an open circuit are not.
+// A zero RetryPolicy makes a single attempt.
+type RetryPolicy struct {
+	// MaxAttempts maximum number of attempts, including the first one.
+	MaxAttempts int
+	// InitialBackoff delay before the first retry, doubled for every following retry.
+	InitialBackoff time.Duration
+	// MaxBackoff upper bound of the delay between attempts, defaults to DefaultMaxBackoff.
+	// Requests are not retried if the remote service asks for a longer delay with Retry-After.
+	MaxBackoff time.Duration
+	// RetryableStatuses response statuses that are retried.
+	RetryableStatuses []int
+}
+
+// DefaultRetryPolicy creates a RetryPolicy retrying 502, 503 and 504 responses up to three attempts.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:       DefaultMaxAttempts,
+		InitialBackoff:    DefaultInitialBackoff,
+		MaxBackoff:        DefaultMaxBackoff,
+		RetryableStatuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+	}
+}
+
+// WithIdempotencyKey returns a context that makes requests carry an idempotency key,
+// allowing requests with non idempotent methods to be retried.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// IdempotencyKeyFromContext returns the idempotency key set on a context with WithIdempotencyKey.
+func IdempotencyKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(idempotencyKeyContextKey{}).(string)
+	return key, ok && key != ""
+}
+
+func (p RetryPolicy) maxAttempts(ctx context.Context, method string) int {
+	if p.MaxAttempts <= 1 {
+		return 1
+	}
+
+	_, hasKey := IdempotencyKeyFromContext(ctx)
+	if !hasKey && !isIdempotent(method) {
+		return 1
+	}
+
+	return p.MaxAttempts
+}
+
+// shouldRetry checks if a failed attempt should be retried. Requests failing without a response are
+// retried based on the transport error, only requests failing with a response are retried based on its status.
+func (p RetryPolicy) shouldRetry(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+
+	var urlErr *url.Error
+	var httpErr *httputil.Error
+	if errors.As(err, &urlErr) || !errors.As(err, &httpErr) {
+		return isTransientTransportError(err)
+	}
+
+	for _, status := range p.RetryableStatuses {
+		if httpErr.Status == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isTransientTransportError checks if a transport error is a timeout or a refused, reset or dropped connection.
+// Permanent failures, such as unknown hosts, unsupported schemes or invalid certificates, are not transient.
+func isTransientTransportError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// backoff returns the delay before a retry, honouring the Retry-After header of the failed response.
+// The exponential backoff is jittered between half and the full delay. Returns false if the
+// failed response asks for a delay longer than the max backoff, in which case it is not retried.
+func (p RetryPolicy) backoff(attempt int, err error) (time.Duration, bool) {
+	maxBackoff := p.maxBackoff()
+	retryAfter, ok := rpc.RetryAfter(err)
+	if ok {
+		return retryAfter, retryAfter <= maxBackoff
+	}
+
+	delay := p.InitialBackoff
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	if delay <= 0 {
+		return 0, true
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1)), true
+}
+
+func (p RetryPolicy) maxBackoff() time.Duration {
+	if p.MaxBackoff <= 0 {
+		return DefaultMaxBackoff
+	}
+
+	return p.MaxBackoff
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,213 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/httputil"
+	"github.com/CzarSimon/httputil/client/rpc"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	prometheustest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetries(t *testing.T) {
+	assert := assert.New(t)
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	var calls int32
+	var idempotencyKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey = r.Header.Get(IdempotencyKeyHeader)
+		if atomic.AddInt32(&calls, 1)%3 != 0 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status": "OK"}`))
+	}))
+	defer server.Close()
+
+	c := &Client{
+		BaseURL:   server.URL,
+		RPCClient: rpc.NewClient(time.Second),
+		Retry:     DefaultRetryPolicy(),
+	}
+
+	parent := tracer.StartSpan("parent")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+	var body map[string]string
+	err := c.Get(ctx, "/retried", &body)
+	assert.NoError(err)
+	assert.Equal("OK", body["status"])
+	assert.Equal(int32(3), atomic.LoadInt32(&calls))
+
+	endpoint := server.URL + "/retried"
+	assert.Equal(1.0, prometheustest.ToFloat64(rpcsTotal.WithLabelValues(endpoint, http.MethodGet, "503", "1")))
+	assert.Equal(1.0, prometheustest.ToFloat64(rpcsTotal.WithLabelValues(endpoint, http.MethodGet, "503", "2")))
+	assert.Equal(1.0, prometheustest.ToFloat64(rpcsTotal.WithLabelValues(endpoint, http.MethodGet, "200", "3")))
+
+	spans := tracer.FinishedSpans()
+	assert.Len(spans, 3)
+	for i, span := range spans {
+		assert.Equal(i+1, span.Tag("attempt"))
+		assert.Equal(parent.Context().(mocktracer.MockSpanContext).SpanID, span.ParentID)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	err = c.Post(context.Background(), "/not-idempotent", nil, nil)
+	assert.True(rpc.HasStatus(err, http.StatusServiceUnavailable), err)
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+
+	atomic.StoreInt32(&calls, 0)
+	err = c.Post(WithIdempotencyKey(context.Background(), "key-1"), "/idempotency-key", nil, nil)
+	assert.NoError(err)
+	assert.Equal(int32(3), atomic.LoadInt32(&calls))
+	assert.Equal("key-1", idempotencyKey)
+
+	atomic.StoreInt32(&calls, 0)
+	c.Retry.MaxAttempts = 2
+	err = c.Get(context.Background(), "/exhausted", nil)
+	assert.True(rpc.HasStatus(err, http.StatusServiceUnavailable), err)
+	assert.Equal(int32(2), atomic.LoadInt32(&calls))
+
+	atomic.StoreInt32(&calls, 0)
+	c.Retry = RetryPolicy{}
+	err = c.Get(context.Background(), "/no-retries", nil)
+	assert.Error(err)
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestRetryConnectionReset(t *testing.T) {
+	assert := assert.New(t)
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	c := &Client{
+		BaseURL:   server.URL,
+		RPCClient: rpc.NewClient(time.Second),
+		Retry:     DefaultRetryPolicy(),
+	}
+
+	err := c.Get(context.Background(), "/reset", nil)
+	assert.NoError(err)
+	assert.Equal(int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	assert := assert.New(t)
+	policy := DefaultRetryPolicy()
+
+	err := errors.New("connection refused")
+	for attempt := 1; attempt <= 10; attempt++ {
+		delay, ok := policy.backoff(attempt, err)
+		assert.True(ok)
+		max := DefaultInitialBackoff << uint(attempt-1)
+		if max > DefaultMaxBackoff {
+			max = DefaultMaxBackoff
+		}
+
+		assert.True(delay >= max/2 && delay <= max, "attempt %d: %s not within [%s, %s]", attempt, delay, max/2, max)
+	}
+
+	delay, ok := policy.backoff(1, retryAfterError(t, "1"))
+	assert.True(ok)
+	assert.Equal(time.Second, delay)
+
+	_, ok = policy.backoff(1, retryAfterError(t, "3600"))
+	assert.False(ok)
+
+	assert.False(policy.shouldRetry(context.Canceled))
+	assert.False(policy.shouldRetry(httputil.BadRequestf("bad request")))
+	assert.True(policy.shouldRetry(httputil.BadGatewayf("bad gateway")))
+	assert.True(policy.shouldRetry(&url.Error{Op: "Get", URL: "http://svc", Err: syscall.ECONNREFUSED}))
+	assert.True(policy.shouldRetry(&url.Error{Op: "Get", URL: "http://svc", Err: &net.DNSError{IsTimeout: true}}))
+	assert.False(policy.shouldRetry(&url.Error{Op: "Get", URL: "http://svc", Err: &net.DNSError{Err: "no such host", IsNotFound: true}}))
+	assert.False(policy.shouldRetry(&url.Error{Op: "Get", URL: "ftp://svc", Err: errors.New("unsupported protocol scheme")}))
+}
+
+func TestRetryPolicyTransportErrors(t *testing.T) {
+	assert := assert.New(t)
+	policy := DefaultRetryPolicy()
+	client := rpc.NewClient(time.Second)
+
+	for _, target := range []string{"http://unresolvable.invalid/things", "ftp://127.0.0.1/things"} {
+		req, err := client.CreateRequest(http.MethodGet, target, nil)
+		assert.NoError(err)
+		_, err = client.Do(req)
+		assert.True(rpc.HasStatus(err, http.StatusServiceUnavailable), target)
+		assert.False(policy.shouldRetry(err), target)
+	}
+
+	req, err := client.CreateRequest(http.MethodGet, "http://127.0.0.1:1/things", nil)
+	assert.NoError(err)
+	_, err = client.Do(req)
+	assert.True(policy.shouldRetry(err))
+}
+
+func TestRetryAfterExceedingMaxBackoff(t *testing.T) {
+	assert := assert.New(t)
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c := &Client{
+		BaseURL:   server.URL,
+		RPCClient: rpc.NewClient(time.Second),
+		Retry:     DefaultRetryPolicy(),
+	}
+
+	start := time.Now()
+	err := c.Get(context.Background(), "/busy", nil)
+	assert.True(rpc.HasStatus(err, http.StatusServiceUnavailable), err)
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+	assert.True(time.Since(start) < time.Second)
+
+	retryAfter, ok := rpc.RetryAfter(err)
+	assert.True(ok)
+	assert.Equal(time.Hour, retryAfter)
+
+	var httpErr *httputil.Error
+	assert.True(errors.As(err, &httpErr))
+	assert.Empty(httpErr.Extensions)
+}
+
+func retryAfterError(t *testing.T, retryAfter string) error {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", retryAfter)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := rpc.NewClient(time.Second)
+	req, err := client.CreateRequest(http.MethodGet, server.URL, nil)
+	assert.NoError(t, err)
+	_, err = client.Do(req)
+	return err
+}
@@ -0,0 +1,585 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Content types of the default codecs.
+const (
+	ContentTypeJSON      = contentTypeJSON
+	ContentTypeText      = contentTypeText
+	ContentTypeForm      = "application/x-www-form-urlencoded"
+	ContentTypeMultipart = "multipart/form-data"
+	ContentTypeBytes     = "application/octet-stream"
+	ContentTypeProtobuf  = "application/x-protobuf"
+
+	headerAccept = "Accept"
+)
+
+// Codec encodes request bodies and decodes response bodies of a content type.
+type Codec interface {
+	// ContentType media type handled by the codec, e.g. application/json.
+	ContentType() string
+	// CanEncode checks if the codec is able to encode a value.
+	CanEncode(v interface{}) bool
+	// Encode encodes a value, returning the body and its content type including any parameters.
+	Encode(v interface{}) (io.Reader, string, error)
+	// Decode decodes a body with the given content type, including any parameters, into v.
+	Decode(r io.Reader, contentType string, v interface{}) error
+}
+
+// Codecs registry of codecs used to encode request bodies and decode response bodies.
+// Request bodies are encoded with the first registered codec able to encode them, falling back to
+// the fallback codec. Response bodies are decoded with the codec matching the response content type.
+type Codecs struct {
+	fallback Codec
+	mu       sync.RWMutex
+	codecs   []Codec
+}
+
+// NewCodecs creates a new Codecs registry using a fallback codec for values no other codec is able to encode.
+func NewCodecs(fallback Codec, codecs ...Codec) *Codecs {
+	r := &Codecs{
+		fallback: fallback,
+	}
+	for _, codec := range codecs {
+		r.Register(codec)
+	}
+
+	return r
+}
+
+// DefaultCodecs creates a Codecs registry with JSON as fallback along with text, form-urlencoded,
+// multipart, raw bytes and protobuf codecs.
+func DefaultCodecs() *Codecs {
+	return NewCodecs(
+		JSONCodec(),
+		ProtobufCodec(),
+		FormCodec(),
+		MultipartCodec(),
+		BytesCodec(),
+		TextCodec(),
+	)
+}
+
+var defaultCodecs = DefaultCodecs()
+
+// RegisterCodec registers a codec in the default registry, used by NewClient and Decode.
+func RegisterCodec(codec Codec) {
+	defaultCodecs.Register(codec)
+}
+
+// Register registers a codec, replacing any codec registered for the same content type.
+func (r *Codecs) Register(codec Codec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, registered := range r.codecs {
+		if registered.ContentType() == codec.ContentType() {
+			r.codecs[i] = codec
+			return
+		}
+	}
+
+	r.codecs = append(r.codecs, codec)
+}
+
+// Lookup returns the codec for a content type, ignoring any parameters. Content types with a structured
+// syntax suffix, e.g. application/problem+json, fall back to the codec of the suffix, e.g. application/json.
+func (r *Codecs) Lookup(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+
+	codecs := r.all()
+	for _, codec := range codecs {
+		if codec.ContentType() == mediaType {
+			return codec, true
+		}
+	}
+
+	suffixType, ok := structuredSuffixType(mediaType)
+	if !ok {
+		return nil, false
+	}
+	for _, codec := range codecs {
+		if codec.ContentType() == suffixType {
+			return codec, true
+		}
+	}
+
+	return nil, false
+}
+
+// Accept returns the value of an Accept header listing the decodable content types, preferring the fallback codec.
+func (r *Codecs) Accept() string {
+	codecs := r.all()
+	types := make([]string, 0, len(codecs))
+	for i, codec := range codecs {
+		if i == 0 {
+			types = append(types, codec.ContentType())
+			continue
+		}
+		types = append(types, codec.ContentType()+";q=0.9")
+	}
+
+	return strings.Join(types, ", ")
+}
+
+// Encode encodes a request body, returning the body and its content type.
+func (r *Codecs) Encode(v interface{}) (io.Reader, string, error) {
+	r.mu.RLock()
+	codec := r.fallback
+	for _, registered := range r.codecs {
+		if registered.CanEncode(v) {
+			codec = registered
+			break
+		}
+	}
+	r.mu.RUnlock()
+
+	return codec.Encode(v)
+}
+
+// Decode decodes a response body with the codec matching its content type.
+// Returns ErrUnsupportedContentType if no codec matches.
+func (r *Codecs) Decode(res *http.Response, v interface{}) error {
+	contentType := res.Header.Get(headerContentType)
+	codec, ok := r.Lookup(contentType)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnsupportedContentType, contentType)
+	}
+
+	return decodeBody(codec, res, contentType, v)
+}
+
+func (r *Codecs) all() []Codec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]Codec{r.fallback}, r.codecs...)
+}
+
+// decodeWith decodes a response body with a codec, provided that the codec handles the response content type.
+func decodeWith(codec Codec, res *http.Response, v interface{}) error {
+	contentType := res.Header.Get(headerContentType)
+	if !handlesContentType(codec, contentType) {
+		return fmt.Errorf("%w: %q", ErrUnsupportedContentType, contentType)
+	}
+
+	return decodeBody(codec, res, contentType, v)
+}
+
+func decodeBody(codec Codec, res *http.Response, contentType string, v interface{}) error {
+	err := codec.Decode(res.Body, contentType, v)
+	if err != nil {
+		return fmt.Errorf("failed to parse response body\n%w", err)
+	}
+
+	return nil
+}
+
+// handlesContentType checks if a codec handles a content type, either directly or by its structured syntax suffix.
+func handlesContentType(codec Codec, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if mediaType == codec.ContentType() {
+		return true
+	}
+
+	suffixType, ok := structuredSuffixType(mediaType)
+	return ok && suffixType == codec.ContentType()
+}
+
+// structuredSuffixType returns the media type named by the structured syntax suffix of a media type,
+// e.g. application/json for application/problem+json.
+func structuredSuffixType(mediaType string) (string, bool) {
+	slash := strings.Index(mediaType, "/")
+	plus := strings.LastIndex(mediaType, "+")
+	if slash < 0 || plus < slash || plus == len(mediaType)-1 {
+		return "", false
+	}
+
+	return mediaType[:slash+1] + mediaType[plus+1:], true
+}
+
+type codecsContextKey struct{}
+
+// Decode decodes a response body with the codec matching its content type, among the codecs of the
+// client that performed the request, or the default codecs if the response did not come from such a client.
+// Returns ErrUnsupportedContentType if no codec matches.
+func Decode(res *http.Response, v interface{}) error {
+	return codecsOf(res).Decode(res, v)
+}
+
+func codecsOf(res *http.Response) *Codecs {
+	if res.Request == nil {
+		return defaultCodecs
+	}
+
+	codecs, ok := res.Request.Context().Value(codecsContextKey{}).(*Codecs)
+	if !ok {
+		return defaultCodecs
+	}
+
+	return codecs
+}
+
+// JSONCodec creates a codec for application/json bodies, able to encode any value.
+// Registered in Codecs, it also decodes +json types such as application/problem+json.
+func JSONCodec() Codec {
+	return jsonCodec{}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (jsonCodec) CanEncode(v interface{}) bool {
+	return true
+}
+
+func (jsonCodec) Encode(v interface{}) (io.Reader, string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return bytes.NewReader(data), ContentTypeJSON, nil
+}
+
+func (jsonCodec) Decode(r io.Reader, contentType string, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// TextCodec creates a codec decoding text/plain bodies into a *string. Strings are encoded
+// as JSON by default, so the codec is never selected to encode a request body.
+func TextCodec() Codec {
+	return textCodec{}
+}
+
+type textCodec struct{}
+
+func (textCodec) ContentType() string {
+	return ContentTypeText
+}
+
+func (textCodec) CanEncode(v interface{}) bool {
+	return false
+}
+
+func (textCodec) Encode(v interface{}) (io.Reader, string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, "", fmt.Errorf("cannot encode %T as %s", v, ContentTypeText)
+	}
+
+	return strings.NewReader(s), ContentTypeText + "; charset=utf-8", nil
+}
+
+func (textCodec) Decode(r io.Reader, contentType string, v interface{}) error {
+	s, ok := v.(*string)
+	if !ok {
+		return fmt.Errorf("cannot decode %s into %T", ContentTypeText, v)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	*s = string(data)
+	return nil
+}
+
+// FormCodec creates a codec for application/x-www-form-urlencoded bodies, encoding url.Values and decoding into *url.Values.
+func FormCodec() Codec {
+	return formCodec{}
+}
+
+type formCodec struct{}
+
+func (formCodec) ContentType() string {
+	return ContentTypeForm
+}
+
+func (formCodec) CanEncode(v interface{}) bool {
+	_, ok := v.(url.Values)
+	return ok
+}
+
+func (formCodec) Encode(v interface{}) (io.Reader, string, error) {
+	values, ok := v.(url.Values)
+	if !ok {
+		return nil, "", fmt.Errorf("cannot encode %T as %s", v, ContentTypeForm)
+	}
+
+	return strings.NewReader(values.Encode()), ContentTypeForm, nil
+}
+
+func (formCodec) Decode(r io.Reader, contentType string, v interface{}) error {
+	values, ok := v.(*url.Values)
+	if !ok {
+		return fmt.Errorf("cannot decode %s into %T", ContentTypeForm, v)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	*values, err = url.ParseQuery(string(data))
+	return err
+}
+
+// MultipartForm multipart/form-data body of fields and files.
+type MultipartForm struct {
+	Fields url.Values
+	Files  []MultipartFile
+}
+
+// MultipartFile file part of a MultipartForm.
+type MultipartFile struct {
+	Field    string
+	Filename string
+	Content  io.Reader
+}
+
+// MultipartCodec creates a codec for multipart/form-data bodies, encoding and decoding *MultipartForm values.
+// File contents not already held in memory are streamed when encoding, in which case the request
+// body must be closed if the request is never sent. Decoded file contents are held in memory.
+func MultipartCodec() Codec {
+	return multipartCodec{}
+}
+
+type multipartCodec struct{}
+
+func (multipartCodec) ContentType() string {
+	return ContentTypeMultipart
+}
+
+func (multipartCodec) CanEncode(v interface{}) bool {
+	switch v.(type) {
+	case MultipartForm, *MultipartForm:
+		return true
+	default:
+		return false
+	}
+}
+
+func (multipartCodec) Encode(v interface{}) (io.Reader, string, error) {
+	var form MultipartForm
+	switch f := v.(type) {
+	case MultipartForm:
+		form = f
+	case *MultipartForm:
+		form = *f
+	default:
+		return nil, "", fmt.Errorf("cannot encode %T as %s", v, ContentTypeMultipart)
+	}
+
+	if form.inMemory() {
+		body := &bytes.Buffer{}
+		w := multipart.NewWriter(body)
+		err := writeMultipart(w, form)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return body, w.FormDataContentType(), nil
+	}
+
+	pr, pw := io.Pipe()
+	w := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeMultipart(w, form))
+	}()
+
+	return pr, w.FormDataContentType(), nil
+}
+
+// inMemory checks if the contents of all files in the form are already held in memory.
+func (form MultipartForm) inMemory() bool {
+	for _, file := range form.Files {
+		switch file.Content.(type) {
+		case *bytes.Buffer, *bytes.Reader, *strings.Reader:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// writeMultipart writes the parts of a form, streaming file contents without buffering them.
+func writeMultipart(w *multipart.Writer, form MultipartForm) error {
+	for field, values := range form.Fields {
+		for _, value := range values {
+			err := w.WriteField(field, value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, file := range form.Files {
+		part, err := w.CreateFormFile(file.Field, file.Filename)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(part, file.Content)
+		if err != nil {
+			return fmt.Errorf("failed to write multipart file %s: %w", file.Filename, err)
+		}
+	}
+
+	return w.Close()
+}
+
+func (multipartCodec) Decode(r io.Reader, contentType string, v interface{}) error {
+	form, ok := v.(*MultipartForm)
+	if !ok {
+		return fmt.Errorf("cannot decode %s into %T", ContentTypeMultipart, v)
+	}
+
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return err
+	}
+
+	form.Fields = url.Values{}
+	reader := multipart.NewReader(r, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		data, err := ioutil.ReadAll(part)
+		if err != nil {
+			return err
+		}
+
+		if part.FileName() == "" {
+			form.Fields.Add(part.FormName(), string(data))
+			continue
+		}
+
+		form.Files = append(form.Files, MultipartFile{
+			Field:    part.FormName(),
+			Filename: part.FileName(),
+			Content:  bytes.NewReader(data),
+		})
+	}
+}
+
+// BytesCodec creates a codec for application/octet-stream bodies. It streams io.Reader values without buffering,
+// and decodes into a *[]byte or streams into an io.Writer. []byte values are left to the fallback codec, i.e. they
+// are base64 encoded JSON strings with the default codecs, wrap them in a bytes.Reader to send them raw.
+func BytesCodec() Codec {
+	return bytesCodec{}
+}
+
+type bytesCodec struct{}
+
+func (bytesCodec) ContentType() string {
+	return ContentTypeBytes
+}
+
+func (bytesCodec) CanEncode(v interface{}) bool {
+	_, ok := v.(io.Reader)
+	return ok
+}
+
+func (bytesCodec) Encode(v interface{}) (io.Reader, string, error) {
+	switch b := v.(type) {
+	case []byte:
+		return bytes.NewReader(b), ContentTypeBytes, nil
+	case io.Reader:
+		return b, ContentTypeBytes, nil
+	default:
+		return nil, "", fmt.Errorf("cannot encode %T as %s", v, ContentTypeBytes)
+	}
+}
+
+func (bytesCodec) Decode(r io.Reader, contentType string, v interface{}) error {
+	switch b := v.(type) {
+	case *[]byte:
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		*b = data
+		return nil
+	case io.Writer:
+		_, err := io.Copy(b, r)
+		return err
+	default:
+		return fmt.Errorf("cannot decode %s into %T", ContentTypeBytes, v)
+	}
+}
+
+// ProtobufCodec creates a codec for application/x-protobuf bodies, encoding and decoding proto.Message values.
+func ProtobufCodec() Codec {
+	return protobufCodec{}
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (protobufCodec) CanEncode(v interface{}) bool {
+	_, ok := v.(proto.Message)
+	return ok
+}
+
+func (protobufCodec) Encode(v interface{}) (io.Reader, string, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, "", fmt.Errorf("cannot encode %T as %s", v, ContentTypeProtobuf)
+	}
+
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return bytes.NewReader(data), ContentTypeProtobuf, nil
+}
+
+func (protobufCodec) Decode(r io.Reader, contentType string, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("cannot decode %s into %T", ContentTypeProtobuf, v)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	return proto.Unmarshal(data, msg)
+}
@@ -0,0 +1,226 @@
+package rpc_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/httputil/client/rpc"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCodecs(t *testing.T) {
+	assert := assert.New(t)
+	var accept string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	client := rpc.NewClient(time.Second)
+	echo := func(body interface{}) *http.Response {
+		req, err := client.CreateRequest(http.MethodPost, server.URL, body)
+		assert.NoError(err)
+		res, err := client.Do(req)
+		assert.NoError(err)
+		return res
+	}
+
+	var jsonBody map[string]string
+	res := echo(map[string]string{"name": "value"})
+	assert.Equal(rpc.ContentTypeJSON, res.Header.Get("Content-Type"))
+	assert.NoError(rpc.Decode(res, &jsonBody))
+	assert.Equal("value", jsonBody["name"])
+	assert.True(strings.HasPrefix(accept, rpc.ContentTypeJSON+", "))
+	assert.Contains(accept, rpc.ContentTypeProtobuf)
+
+	var form url.Values
+	res = echo(url.Values{"name": {"a&b"}, "list": {"1", "2"}})
+	assert.Equal(rpc.ContentTypeForm, res.Header.Get("Content-Type"))
+	assert.NoError(rpc.Decode(res, &form))
+	assert.Equal("a&b", form.Get("name"))
+	assert.Equal([]string{"1", "2"}, form["list"])
+
+	var multipart rpc.MultipartForm
+	res = echo(&rpc.MultipartForm{
+		Fields: url.Values{"name": {"report"}},
+		Files: []rpc.MultipartFile{
+			{Field: "file", Filename: "report.csv", Content: strings.NewReader("a,b\n1,2\n")},
+		},
+	})
+	assert.NoError(rpc.Decode(res, &multipart))
+	assert.Equal("report", multipart.Fields.Get("name"))
+	assert.Len(multipart.Files, 1)
+	assert.Equal("report.csv", multipart.Files[0].Filename)
+	content, err := ioutil.ReadAll(multipart.Files[0].Content)
+	assert.NoError(err)
+	assert.Equal("a,b\n1,2\n", string(content))
+
+	var raw []byte
+	res = echo(bytes.NewReader([]byte{0, 1, 2}))
+	assert.Equal(rpc.ContentTypeBytes, res.Header.Get("Content-Type"))
+	assert.NoError(rpc.Decode(res, &raw))
+	assert.Equal([]byte{0, 1, 2}, raw)
+
+	raw = nil
+	res = echo([]byte{0, 1, 2})
+	assert.Equal(rpc.ContentTypeJSON, res.Header.Get("Content-Type"))
+	assert.NoError(rpc.Decode(res, &raw))
+	assert.Equal([]byte{0, 1, 2}, raw)
+
+	streamed := &bytes.Buffer{}
+	res = echo(strings.NewReader("streamed body"))
+	assert.NoError(rpc.Decode(res, streamed))
+	assert.Equal("streamed body", streamed.String())
+
+	msg := &wrapperspb.StringValue{}
+	res = echo(wrapperspb.String("protobuf"))
+	assert.Equal(rpc.ContentTypeProtobuf, res.Header.Get("Content-Type"))
+	assert.NoError(rpc.Decode(res, msg))
+	assert.True(proto.Equal(wrapperspb.String("protobuf"), msg))
+}
+
+func TestClientCodecsDecodeResponses(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write([]byte("a,b\n1,2\n"))
+	}))
+	defer server.Close()
+
+	client := rpc.NewClientWithCodecs(time.Second, rpc.NewCodecs(rpc.JSONCodec(), csvCodec{}))
+	req, err := client.CreateRequest(http.MethodGet, server.URL, nil)
+	assert.NoError(err)
+	assert.Contains(req.Header.Get("Accept"), "text/csv")
+	res, err := client.Do(req)
+	assert.NoError(err)
+
+	var rows [][]string
+	assert.NoError(rpc.Decode(res, &rows))
+	assert.Equal([][]string{{"a", "b"}, {"1", "2"}}, rows)
+
+	req, err = rpc.NewClient(time.Second).CreateRequest(http.MethodGet, server.URL, nil)
+	assert.NoError(err)
+	res, err = rpc.NewClient(time.Second).Do(req)
+	assert.NoError(err)
+	err = rpc.Decode(res, &rows)
+	assert.True(errors.Is(err, rpc.ErrUnsupportedContentType), err)
+}
+
+type csvCodec struct{}
+
+func (csvCodec) ContentType() string {
+	return "text/csv"
+}
+
+func (csvCodec) CanEncode(v interface{}) bool {
+	_, ok := v.([][]string)
+	return ok
+}
+
+func (csvCodec) Encode(v interface{}) (io.Reader, string, error) {
+	var buf bytes.Buffer
+	err := csv.NewWriter(&buf).WriteAll(v.([][]string))
+	return &buf, "text/csv", err
+}
+
+func (csvCodec) Decode(r io.Reader, contentType string, v interface{}) error {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return err
+	}
+
+	*v.(*[][]string) = rows
+	return nil
+}
+
+func TestStrictDecoding(t *testing.T) {
+	assert := assert.New(t)
+	response := func(contentType, body string) *http.Response {
+		return &http.Response{
+			Header: http.Header{"Content-Type": {contentType}},
+			Body:   ioutil.NopCloser(strings.NewReader(body)),
+		}
+	}
+
+	var v map[string]string
+	err := rpc.DecodeJSON(response("text/html", `{"name": "value"}`), &v)
+	assert.True(errors.Is(err, rpc.ErrUnsupportedContentType), err)
+
+	err = rpc.DecodeJSON(response("", `{"name": "value"}`), &v)
+	assert.True(errors.Is(err, rpc.ErrUnsupportedContentType), err)
+
+	err = rpc.DecodeJSON(response("application/json; charset=utf-8", `{"name": "value"}`), &v)
+	assert.NoError(err)
+	assert.Equal("value", v["name"])
+
+	for _, contentType := range []string{"application/problem+json", "application/vnd.thing.v1+json; charset=utf-8"} {
+		v = nil
+		err = rpc.DecodeJSON(response(contentType, `{"name": "value"}`), &v)
+		assert.NoError(err, contentType)
+		assert.Equal("value", v["name"])
+
+		v = nil
+		err = rpc.Decode(response(contentType, `{"name": "value"}`), &v)
+		assert.NoError(err, contentType)
+		assert.Equal("value", v["name"])
+	}
+
+	err = rpc.DecodeJSON(response("application/vnd.thing+xml", `{"name": "value"}`), &v)
+	assert.True(errors.Is(err, rpc.ErrUnsupportedContentType), err)
+
+	_, err = rpc.DecodeText(response("application/json", `"text"`))
+	assert.True(errors.Is(err, rpc.ErrUnsupportedContentType), err)
+
+	text, err := rpc.DecodeText(response("text/plain; charset=utf-8", "text"))
+	assert.NoError(err)
+	assert.Equal("text", text)
+
+	err = rpc.Decode(response("application/xml", "<name>value</name>"), &v)
+	assert.True(errors.Is(err, rpc.ErrUnsupportedContentType), err)
+
+	codecs := rpc.NewCodecs(rpc.JSONCodec())
+	codecs.Register(rpc.TextCodec())
+	assert.Equal("application/json, text/plain;q=0.9", codecs.Accept())
+
+	codec, ok := codecs.Lookup("application/problem+json")
+	assert.True(ok)
+	assert.Equal(rpc.ContentTypeJSON, codec.ContentType())
+	_, ok = codecs.Lookup("application/vnd.thing+xml")
+	assert.False(ok)
+}
+
+func TestMultipartCodecBuffersInMemoryFiles(t *testing.T) {
+	assert := assert.New(t)
+	codec := rpc.MultipartCodec()
+
+	body, _, err := codec.Encode(rpc.MultipartForm{
+		Files: []rpc.MultipartFile{{Field: "file", Filename: "a.txt", Content: strings.NewReader("a")}},
+	})
+	assert.NoError(err)
+	_, buffered := body.(*bytes.Buffer)
+	assert.True(buffered)
+
+	body, _, err = codec.Encode(rpc.MultipartForm{
+		Files: []rpc.MultipartFile{{Field: "file", Filename: "b.txt", Content: ioutil.NopCloser(strings.NewReader("b"))}},
+	})
+	assert.NoError(err)
+	_, buffered = body.(*bytes.Buffer)
+	assert.False(buffered)
+	data, err := ioutil.ReadAll(body)
+	assert.NoError(err)
+	assert.Contains(string(data), `filename="b.txt"`)
+}
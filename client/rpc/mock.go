@@ -12,10 +12,15 @@ import (
 	"github.com/CzarSimon/httputil"
 )
 
-// MockResponse mocked rpc response.
+// MockResponse mocked rpc response. Responses with a status of 300 or above
+// fail like a real client would, with the body decoded as the remote error.
 type MockResponse struct {
-	Body interface{}
-	Err  error
+	Body   interface{}
+	Err    error
+	Status int
+	Header http.Header
+	// Delay simulated latency of the response.
+	Delay time.Duration
 }
 
 // MockResponses is a MockResponse map
@@ -27,9 +32,9 @@ type MockClient struct {
 	Responses MockResponses
 }
 
-// Do perform a mked request.
+// Do perform a mocked request.
 func (c *MockClient) Do(req *http.Request) (*http.Response, error) {
-	time.Sleep(10 * time.Millisecond)
+	closeRequestBody(req)
 	key := fmt.Sprintf("%s:%s", req.Method, req.URL)
 	mockRes, ok := c.Responses[key]
 	if !ok {
@@ -37,32 +42,61 @@ func (c *MockClient) Do(req *http.Request) (*http.Response, error) {
 		return nil, httputil.NotFoundError(err)
 	}
 
+	time.Sleep(mockRes.Delay)
 	if mockRes.Err != nil {
 		return nil, mockRes.Err
 	}
 
 	var body io.ReadCloser
 	headers := http.Header{}
+	for name, values := range mockRes.Header {
+		headers[name] = values
+	}
 	if mockRes.Body != nil {
 		bytesBody, err := json.Marshal(mockRes.Body)
 		if err != nil {
 			return nil, err
 		}
 		body = ioutil.NopCloser(bytes.NewBuffer(bytesBody))
-		headers.Add("Content-Type", "application/json")
+		if headers.Get(headerContentType) == "" {
+			headers.Set(headerContentType, contentTypeJSON)
+		}
 	} else {
 		body = http.NoBody
 	}
 
-	status := http.StatusOK
-	res := &http.Response{
+	status := mockRes.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	return respond(req, newResponse(status, headers, body))
+}
+
+// closeRequestBody closes the body of a request that is not sent, releasing any goroutine streaming it.
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+func newResponse(status int, header http.Header, body io.ReadCloser) *http.Response {
+	return &http.Response{
 		Proto:      "HTTP/1.1",
 		ProtoMajor: 1,
 		ProtoMinor: 1,
-		Status:     fmt.Sprintf("%d - %s", status, http.StatusText(status)),
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
 		StatusCode: status,
 		Body:       body,
-		Header:     headers,
+		Header:     header,
+	}
+}
+
+// respond returns a mocked response the way a real client would, failing with the remote error for error statuses.
+func respond(req *http.Request, res *http.Response) (*http.Response, error) {
+	err := wrapRequestError(req, res, nil)
+	if err != nil {
+		return nil, err
 	}
 
 	return res, nil
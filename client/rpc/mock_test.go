@@ -0,0 +1,45 @@
+package rpc_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/httputil"
+	"github.com/CzarSimon/httputil/client/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockClient(t *testing.T) {
+	assert := assert.New(t)
+	client := &rpc.MockClient{
+		Client: rpc.NewClient(time.Second),
+		Responses: rpc.MockResponses{
+			"GET:http://svc/things/1": {
+				Body: thing{ID: "1"},
+			},
+			"GET:http://svc/things/2": {
+				Status: http.StatusConflict,
+				Body:   httputil.Error{ID: "err-2", Status: http.StatusConflict, Message: "Conflict"},
+				Header: http.Header{"X-Request-Id": []string{"req-1"}},
+			},
+		},
+	}
+
+	req, err := client.CreateRequest(http.MethodGet, "http://svc/things/1", nil)
+	assert.NoError(err)
+	res, err := client.Do(req)
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	var got thing
+	assert.NoError(rpc.DecodeJSON(res, &got))
+	assert.Equal("1", got.ID)
+
+	req, err = client.CreateRequest(http.MethodGet, "http://svc/things/2", nil)
+	assert.NoError(err)
+	_, err = client.Do(req)
+	assert.True(rpc.HasStatus(err, http.StatusConflict))
+	remoteErr, ok := rpc.GetRemoteError(err)
+	assert.True(ok)
+	assert.Equal("err-2", remoteErr.ID)
+}
@@ -0,0 +1,237 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"unicode/utf8"
+)
+
+// Fixture recorded request and response pair, served by a ReplayClient.
+type Fixture struct {
+	Request  FixtureRequest  `json:"request"`
+	Response FixtureResponse `json:"response"`
+}
+
+// FixtureRequest recorded request.
+type FixtureRequest struct {
+	Method string       `json:"method"`
+	URL    string       `json:"url"`
+	Body   *FixtureBody `json:"body,omitempty"`
+}
+
+// FixtureResponse recorded response. Error is set instead of a status if the request failed without a response.
+type FixtureResponse struct {
+	Status int          `json:"status,omitempty"`
+	Header http.Header  `json:"header,omitempty"`
+	Body   *FixtureBody `json:"body,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// FixtureBody recorded body, stored as text or, if it is not valid UTF-8, base64 encoded.
+type FixtureBody struct {
+	Text   string `json:"text,omitempty"`
+	Base64 string `json:"base64,omitempty"`
+}
+
+func newFixtureBody(data []byte) *FixtureBody {
+	if len(data) == 0 {
+		return nil
+	}
+	if utf8.Valid(data) {
+		return &FixtureBody{Text: string(data)}
+	}
+
+	return &FixtureBody{Base64: base64.StdEncoding.EncodeToString(data)}
+}
+
+// Bytes returns the content of the body, which is empty for a nil body.
+func (b *FixtureBody) Bytes() ([]byte, error) {
+	if b == nil {
+		return nil, nil
+	}
+	if b.Base64 != "" {
+		return base64.StdEncoding.DecodeString(b.Base64)
+	}
+
+	return []byte(b.Text), nil
+}
+
+func (r FixtureRequest) String() string {
+	return fmt.Sprintf("%s %s", r.Method, r.URL)
+}
+
+// LoadFixtures reads fixtures from a JSON file.
+func LoadFixtures(path string) ([]Fixture, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures %s: %w", path, err)
+	}
+
+	var fixtures []Fixture
+	err = json.Unmarshal(data, &fixtures)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse fixtures %s: %w", path, err)
+	}
+
+	return fixtures, nil
+}
+
+// SaveFixtures writes fixtures to a JSON file.
+func SaveFixtures(path string, fixtures []Fixture) error {
+	data, err := json.MarshalIndent(fixtures, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode fixtures: %w", err)
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Recorder rpc client decorator recording the requests made through a wrapped client and the responses
+// received as fixtures, which can be saved and served by a ReplayClient. Responses received by clients
+// created with NewClient or NewClientWithCodecs, including error responses, are recorded byte for byte
+// with their status and headers, while other failed requests are recorded as errors. Request and response
+// bodies are buffered in memory. Request headers are not recorded, so credentials never end up in fixtures.
+type Recorder struct {
+	client   Client
+	mu       sync.Mutex
+	fixtures []Fixture
+}
+
+// NewRecorder creates a Recorder wrapping a client.
+func NewRecorder(client Client) *Recorder {
+	return &Recorder{
+		client: client,
+	}
+}
+
+// CreateRequest creates a request with the wrapped client.
+func (r *Recorder) CreateRequest(method, url string, body interface{}) (*http.Request, error) {
+	return r.client.CreateRequest(method, url, body)
+}
+
+// Do performs a request with the wrapped client and records it along with the response or error.
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	fixture := Fixture{
+		Request: FixtureRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Body:   newFixtureBody(reqBody),
+		},
+	}
+
+	raw := &rawResponse{}
+	req = req.WithContext(context.WithValue(req.Context(), rawResponseContextKey{}, raw))
+	res, err := r.client.Do(req)
+	switch {
+	case raw.recorded:
+		fixture.Response = raw.response
+	case err != nil:
+		fixture.Response = FixtureResponse{Error: transportError(err).Error()}
+	default:
+		fixture.Response, err = recordResponse(res)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r.mu.Lock()
+	r.fixtures = append(r.fixtures, fixture)
+	r.mu.Unlock()
+	return res, err
+}
+
+// Fixtures returns the fixtures recorded so far.
+func (r *Recorder) Fixtures() []Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fixtures := make([]Fixture, len(r.fixtures))
+	copy(fixtures, r.fixtures)
+	return fixtures
+}
+
+// Save writes the recorded fixtures to a JSON file.
+func (r *Recorder) Save(path string) error {
+	return SaveFixtures(path, r.Fixtures())
+}
+
+// readRequestBody reads the body of a request and replaces it, so that it can still be sent.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	return body, nil
+}
+
+type rawResponseContextKey struct{}
+
+// rawResponse response received for a request made through a Recorder, before it is turned into an error.
+type rawResponse struct {
+	response FixtureResponse
+	recorded bool
+}
+
+// recordRawResponse records the response of a request made through a Recorder, if any, as received.
+func recordRawResponse(req *http.Request, res *http.Response) {
+	raw, ok := req.Context().Value(rawResponseContextKey{}).(*rawResponse)
+	if !ok {
+		return
+	}
+
+	response, err := recordResponse(res)
+	if err != nil {
+		return
+	}
+
+	raw.response = response
+	raw.recorded = true
+}
+
+// transportError returns the error of the transport wrapped by an error, if any.
+func transportError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+
+	return err
+}
+
+func recordResponse(res *http.Response) (FixtureResponse, error) {
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return FixtureResponse{}, fmt.Errorf("failed to read response body: %w", err)
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	header := res.Header.Clone()
+	header.Del("Date")
+	return FixtureResponse{
+		Status: res.StatusCode,
+		Header: header,
+		Body:   newFixtureBody(body),
+	}, nil
+}
@@ -0,0 +1,106 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/CzarSimon/httputil"
+)
+
+// maxErrorBodySize upper bound of the error response body read when decoding remote errors.
+const maxErrorBodySize = 64 << 10
+
+// RemoteError error reported by a downstream service in the body of a failed response,
+// either in the default httputil.Error shape or as problem+json.
+type RemoteError struct {
+	ID         string
+	Code       string
+	Status     int
+	Message    string
+	Violations []httputil.FieldViolation
+}
+
+func (err *RemoteError) Error() string {
+	if err.Code != "" {
+		return fmt.Sprintf("RemoteError(id=%s, code=%s, message=%s, status=%d)", err.ID, err.Code, err.Message, err.Status)
+	}
+	return fmt.Sprintf("RemoteError(id=%s, message=%s, status=%d)", err.ID, err.Message, err.Status)
+}
+
+// GetRemoteError returns the error reported by a downstream service from an error chain, if any.
+func GetRemoteError(err error) (*RemoteError, bool) {
+	var remoteErr *RemoteError
+	ok := errors.As(err, &remoteErr)
+	return remoteErr, ok
+}
+
+// decodeRemoteError parses the error body of a failed response. Returns false if the body is not a recognised error.
+func decodeRemoteError(res *http.Response) (*RemoteError, bool) {
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxErrorBodySize))
+	if err != nil || len(body) == 0 {
+		return nil, false
+	}
+
+	contentType := res.Header.Get(headerContentType)
+	switch {
+	case strings.HasPrefix(contentType, httputil.MIMEProblemJSON):
+		return decodeProblem(body)
+	case strings.HasPrefix(contentType, contentTypeJSON):
+		return decodeError(body)
+	default:
+		return nil, false
+	}
+}
+
+func decodeError(body []byte) (*RemoteError, bool) {
+	var httpErr httputil.Error
+	err := json.Unmarshal(body, &httpErr)
+	if err != nil || (httpErr.ID == "" && httpErr.Message == "") {
+		return nil, false
+	}
+
+	return &RemoteError{
+		ID:         httpErr.ID,
+		Code:       httpErr.Code,
+		Status:     httpErr.Status,
+		Message:    httpErr.Message,
+		Violations: httpErr.Violations,
+	}, true
+}
+
+func decodeProblem(body []byte) (*RemoteError, bool) {
+	var problem httputil.Problem
+	err := json.Unmarshal(body, &problem)
+	if err != nil {
+		return nil, false
+	}
+
+	remoteErr := &RemoteError{
+		ID:      problem.Instance,
+		Status:  problem.Status,
+		Message: problem.Detail,
+	}
+	if remoteErr.Message == "" {
+		remoteErr.Message = problem.Title
+	}
+
+	code, ok := problem.Extensions["code"].(string)
+	if ok {
+		remoteErr.Code = code
+	}
+
+	violations, ok := problem.Extensions["violations"]
+	if ok {
+		data, err := json.Marshal(violations)
+		if err == nil {
+			json.Unmarshal(data, &remoteErr.Violations)
+		}
+	}
+
+	return remoteErr, true
+}
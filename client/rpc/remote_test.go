@@ -0,0 +1,62 @@
+package rpc_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/httputil"
+	"github.com/CzarSimon/httputil/client/rpc"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoteError(t *testing.T) {
+	assert := assert.New(t)
+	var remoteID string
+	failing := func(c *gin.Context) {
+		err := httputil.Conflictf("user already exists")
+		err.Message = "User already exists"
+		err.Code = "USER_EXISTS"
+		remoteID = err.ID
+		c.Error(err)
+	}
+
+	r := gin.New()
+	r.GET("/default", httputil.HandleErrors(), failing)
+	r.GET("/problem", httputil.HandleErrors(httputil.ProblemJSON()), failing)
+	r.GET("/text", func(c *gin.Context) {
+		c.String(http.StatusBadGateway, "upstream failed")
+	})
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	client := rpc.NewClient(time.Second)
+	for _, path := range []string{"/default", "/problem"} {
+		req, err := client.CreateRequest(http.MethodGet, server.URL+path, nil)
+		assert.NoError(err)
+
+		_, err = client.Do(req)
+		assert.True(rpc.HasStatus(err, http.StatusConflict), path)
+
+		var httpErr *httputil.Error
+		assert.True(errors.As(err, &httpErr), path)
+		assert.NotEqual(remoteID, httpErr.ID, path)
+
+		remoteErr, ok := rpc.GetRemoteError(err)
+		assert.True(ok, path)
+		assert.Equal(remoteID, remoteErr.ID, path)
+		assert.Equal("USER_EXISTS", remoteErr.Code, path)
+		assert.Equal(http.StatusConflict, remoteErr.Status, path)
+		assert.Equal("User already exists", remoteErr.Message, path)
+	}
+
+	req, err := client.CreateRequest(http.MethodGet, server.URL+"/text", nil)
+	assert.NoError(err)
+	_, err = client.Do(req)
+	assert.True(rpc.HasStatus(err, http.StatusBadGateway))
+	_, ok := rpc.GetRemoteError(err)
+	assert.False(ok)
+}
@@ -0,0 +1,238 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CzarSimon/httputil"
+)
+
+// maxDescribedBodySize upper bound of the request body included when describing unmatched requests.
+const maxDescribedBodySize = 256
+
+// Matcher checks if a request, with its body read into memory, matches a recorded request.
+type Matcher func(req *http.Request, body []byte, fixture FixtureRequest) bool
+
+// DefaultMatchers matchers used by a ReplayClient unless others are supplied, matching method, path and query.
+var DefaultMatchers = []Matcher{MatchMethod, MatchPath, MatchQuery}
+
+// MatchMethod matches requests with the same method.
+func MatchMethod(req *http.Request, body []byte, fixture FixtureRequest) bool {
+	return req.Method == fixture.Method
+}
+
+// MatchPath matches requests with the same scheme, host and path.
+func MatchPath(req *http.Request, body []byte, fixture FixtureRequest) bool {
+	u, err := url.Parse(fixture.URL)
+	if err != nil {
+		return false
+	}
+
+	return req.URL.Scheme == u.Scheme && req.URL.Host == u.Host && req.URL.Path == u.Path
+}
+
+// MatchQuery matches requests with the same query parameters, regardless of their order.
+func MatchQuery(req *http.Request, body []byte, fixture FixtureRequest) bool {
+	u, err := url.Parse(fixture.URL)
+	if err != nil {
+		return false
+	}
+
+	expected := u.Query()
+	actual := req.URL.Query()
+	if len(expected) == 0 && len(actual) == 0 {
+		return true
+	}
+
+	return reflect.DeepEqual(expected, actual)
+}
+
+// MatchBody matches requests with the same body. JSON bodies are compared by value,
+// ignoring formatting and key order, while other bodies must be identical.
+func MatchBody(req *http.Request, body []byte, fixture FixtureRequest) bool {
+	expected, err := fixture.Body.Bytes()
+	if err != nil {
+		return false
+	}
+
+	var expectedJSON, actualJSON interface{}
+	if json.Unmarshal(expected, &expectedJSON) == nil && json.Unmarshal(body, &actualJSON) == nil {
+		return reflect.DeepEqual(expectedJSON, actualJSON)
+	}
+
+	return bytes.Equal(expected, body)
+}
+
+// ReplayClient mock rpc client serving recorded fixtures, e.g. recorded with a Recorder.
+// Every fixture is served once, in the order recorded, to the first request matching it.
+// Requests are created with the default codecs. Use it like:
+//
+//	client, err := rpc.LoadReplayClient("testdata/fixtures.json")
+//	...
+//	assert.NoError(client.ExpectationsWereMet())
+type ReplayClient struct {
+	Client
+	// Delay simulated latency of every response.
+	Delay time.Duration
+
+	matchers  []Matcher
+	mu        sync.Mutex
+	fixtures  []Fixture
+	served    []bool
+	unmatched []string
+}
+
+// NewReplayClient creates a ReplayClient serving fixtures to the requests satisfying all matchers,
+// or the DefaultMatchers if none are supplied.
+func NewReplayClient(fixtures []Fixture, matchers ...Matcher) *ReplayClient {
+	if len(matchers) == 0 {
+		matchers = DefaultMatchers
+	}
+
+	return &ReplayClient{
+		Client:   NewClient(0),
+		matchers: matchers,
+		fixtures: fixtures,
+		served:   make([]bool, len(fixtures)),
+	}
+}
+
+// LoadReplayClient creates a ReplayClient serving the fixtures in a JSON file, see NewReplayClient.
+func LoadReplayClient(path string, matchers ...Matcher) (*ReplayClient, error) {
+	fixtures, err := LoadFixtures(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewReplayClient(fixtures, matchers...), nil
+}
+
+// Do serves the first fixture matching a request that has not been served yet.
+// Fails with a not found error describing the request if no fixture matches.
+func (c *ReplayClient) Do(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	fixture, ok := c.match(req, body)
+	if !ok {
+		return nil, httputil.NotFoundError(c.unmatchedError(req, body))
+	}
+
+	time.Sleep(c.Delay)
+	res := fixture.Response
+	if res.Error != "" {
+		err = &url.Error{Op: urlErrorOp(req.Method), URL: req.URL.String(), Err: errors.New(res.Error)}
+		return nil, wrapRequestError(req, nil, err)
+	}
+
+	resBody, err := res.Body.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode fixture body of %s: %w", fixture.Request, err)
+	}
+
+	header := res.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return respond(req, newResponse(res.Status, header, ioutil.NopCloser(bytes.NewReader(resBody))))
+}
+
+// ExpectationsWereMet checks that every fixture has been served and that no unmatched requests were made.
+func (c *ReplayClient) ExpectationsWereMet() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	problems := make([]string, 0)
+	for i, fixture := range c.fixtures {
+		if !c.served[i] {
+			problems = append(problems, fmt.Sprintf("expected request %s was not made", fixture.Request))
+		}
+	}
+	for _, req := range c.unmatched {
+		problems = append(problems, fmt.Sprintf("unexpected request %s", req))
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("replay expectations were not met:\n\t%s", strings.Join(problems, "\n\t"))
+}
+
+func (c *ReplayClient) match(req *http.Request, body []byte) (Fixture, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, fixture := range c.fixtures {
+		if !c.served[i] && c.matches(req, body, fixture.Request) {
+			c.served[i] = true
+			return fixture, true
+		}
+	}
+
+	c.unmatched = append(c.unmatched, describeRequest(req, body))
+	return Fixture{}, false
+}
+
+func (c *ReplayClient) matches(req *http.Request, body []byte, fixture FixtureRequest) bool {
+	for _, match := range c.matchers {
+		if !match(req, body, fixture) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// unmatchedError describes an unmatched request along with the fixtures that remain to be served.
+func (c *ReplayClient) unmatchedError(req *http.Request, body []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	remaining := make([]string, 0)
+	for i, fixture := range c.fixtures {
+		if !c.served[i] {
+			remaining = append(remaining, fixture.Request.String())
+		}
+	}
+
+	if len(remaining) == 0 {
+		return fmt.Errorf("no fixture matches request %s, all fixtures have been served", describeRequest(req, body))
+	}
+
+	return fmt.Errorf("no fixture matches request %s, remaining fixtures:\n\t%s", describeRequest(req, body), strings.Join(remaining, "\n\t"))
+}
+
+// urlErrorOp returns the operation of a url.Error for a request method, formatted like http.Client does.
+func urlErrorOp(method string) string {
+	if method == "" {
+		return "Get"
+	}
+
+	return method[:1] + strings.ToLower(method[1:])
+}
+
+func describeRequest(req *http.Request, body []byte) string {
+	description := fmt.Sprintf("%s %s", req.Method, req.URL)
+	if len(body) == 0 {
+		return description
+	}
+
+	if len(body) > maxDescribedBodySize {
+		return fmt.Sprintf("%s with body %s...", description, body[:maxDescribedBodySize])
+	}
+
+	return fmt.Sprintf("%s with body %s", description, body)
+}
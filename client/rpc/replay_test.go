@@ -0,0 +1,219 @@
+package rpc_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/httputil"
+	"github.com/CzarSimon/httputil/client/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+type thing struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+const problemBody = `{"type":"about:blank","title":"Conflict","status":409,"detail":"The thing is already taken","code":"thing-taken"}`
+
+func TestRecordAndReplay(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/things/1":
+			json.NewEncoder(w).Encode(thing{ID: "1", Name: "first"})
+		case r.Method == http.MethodPost && r.URL.Path == "/things":
+			var body thing
+			json.NewDecoder(r.Body).Decode(&body)
+			body.ID = "2"
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(body)
+		case r.URL.Path == "/problem":
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.Header().Set("X-Error-Source", "upstream")
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(problemBody))
+		case r.URL.Path == "/plain":
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("upstream exploded"))
+		default:
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(httputil.Error{ID: "err-1", Status: http.StatusServiceUnavailable, Message: "Service Unavailable"})
+		}
+	}))
+	defer server.Close()
+
+	run := func(client rpc.Client) {
+		req, err := client.CreateRequest(http.MethodGet, server.URL+"/things/1?b=2&a=1", nil)
+		assert.NoError(err)
+		res, err := client.Do(req)
+		assert.NoError(err)
+		var got thing
+		assert.NoError(rpc.DecodeJSON(res, &got))
+		assert.Equal(thing{ID: "1", Name: "first"}, got)
+
+		req, err = client.CreateRequest(http.MethodPost, server.URL+"/things", thing{Name: "second"})
+		assert.NoError(err)
+		res, err = client.Do(req)
+		assert.NoError(err)
+		assert.Equal(http.StatusCreated, res.StatusCode)
+		assert.NoError(rpc.DecodeJSON(res, &got))
+		assert.Equal(thing{ID: "2", Name: "second"}, got)
+
+		req, err = client.CreateRequest(http.MethodGet, server.URL+"/unavailable", nil)
+		assert.NoError(err)
+		_, err = client.Do(req)
+		assert.True(rpc.HasStatus(err, http.StatusServiceUnavailable))
+		retryAfter, ok := rpc.RetryAfter(err)
+		assert.True(ok)
+		assert.Equal(3*time.Second, retryAfter)
+		remoteErr, ok := rpc.GetRemoteError(err)
+		assert.True(ok)
+		assert.Equal("err-1", remoteErr.ID)
+
+		req, err = client.CreateRequest(http.MethodGet, server.URL+"/problem", nil)
+		assert.NoError(err)
+		_, err = client.Do(req)
+		assert.True(rpc.HasStatus(err, http.StatusConflict))
+		remoteErr, ok = rpc.GetRemoteError(err)
+		assert.True(ok)
+		assert.Equal("thing-taken", remoteErr.Code)
+		assert.Equal("The thing is already taken", remoteErr.Message)
+
+		req, err = client.CreateRequest(http.MethodGet, server.URL+"/plain", nil)
+		assert.NoError(err)
+		_, err = client.Do(req)
+		assert.True(rpc.HasStatus(err, http.StatusBadGateway))
+	}
+
+	recorder := rpc.NewRecorder(rpc.NewClient(time.Second))
+	run(recorder)
+	fixtures := recorder.Fixtures()
+	assert.Len(fixtures, 5)
+	assert.Equal(http.StatusConflict, fixtures[3].Response.Status)
+	assert.Equal("upstream", fixtures[3].Response.Header.Get("X-Error-Source"))
+	assert.Equal(problemBody, fixtures[3].Response.Body.Text)
+	assert.Equal("upstream exploded", fixtures[4].Response.Body.Text)
+	assert.Equal("3", fixtures[2].Response.Header.Get("Retry-After"))
+
+	dir, err := ioutil.TempDir("", "fixtures")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "fixtures.json")
+	assert.NoError(recorder.Save(path))
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(err)
+	assert.False(strings.Contains(string(data), "Date"))
+
+	replay, err := rpc.LoadReplayClient(path, append(rpc.DefaultMatchers, rpc.MatchBody)...)
+	assert.NoError(err)
+	assert.Error(replay.ExpectationsWereMet())
+
+	server.Close()
+	run(replay)
+	assert.NoError(replay.ExpectationsWereMet())
+
+	req, err := replay.CreateRequest(http.MethodGet, server.URL+"/things/1?a=1&b=2", nil)
+	assert.NoError(err)
+	_, err = replay.Do(req)
+	assert.True(rpc.HasStatus(err, http.StatusNotFound))
+	assert.Contains(err.Error(), "GET "+server.URL+"/things/1?a=1&b=2")
+
+	err = replay.ExpectationsWereMet()
+	assert.Error(err)
+	assert.Contains(err.Error(), "unexpected request GET "+server.URL+"/things/1?a=1&b=2")
+}
+
+func TestRecorderWrapsClient(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	recorder := rpc.NewRecorder(rpc.NewClientWithCodecs(time.Second, rpc.NewCodecs(rpc.JSONCodec(), csvCodec{})))
+	req, err := recorder.CreateRequest(http.MethodPost, server.URL+"/rows", [][]string{{"a", "b"}})
+	assert.NoError(err)
+	assert.Equal("text/csv", req.Header.Get("Content-Type"))
+	res, err := recorder.Do(req)
+	assert.NoError(err)
+	var rows [][]string
+	assert.NoError(rpc.Decode(res, &rows))
+	assert.Equal([][]string{{"a", "b"}}, rows)
+
+	recorder = rpc.NewRecorder(&rpc.MockClient{
+		Client: rpc.NewClient(time.Second),
+		Responses: rpc.MockResponses{
+			"GET:http://svc/things/1": {Body: thing{ID: "1"}},
+		},
+	})
+	req, err = recorder.CreateRequest(http.MethodGet, "http://svc/things/1", nil)
+	assert.NoError(err)
+	_, err = recorder.Do(req)
+	assert.NoError(err)
+
+	fixtures := recorder.Fixtures()
+	assert.Len(fixtures, 1)
+	assert.Equal(http.StatusOK, fixtures[0].Response.Status)
+	assert.JSONEq(`{"id": "1", "name": ""}`, fixtures[0].Response.Body.Text)
+}
+
+func TestReplayMatchers(t *testing.T) {
+	assert := assert.New(t)
+	fixtures := []rpc.Fixture{
+		{
+			Request: rpc.FixtureRequest{
+				Method: http.MethodPost,
+				URL:    "http://svc/things?tag=a",
+				Body:   &rpc.FixtureBody{Text: `{"name": "first", "size": 1}`},
+			},
+			Response: rpc.FixtureResponse{Status: http.StatusNoContent},
+		},
+		{
+			Request: rpc.FixtureRequest{
+				Method: http.MethodPost,
+				URL:    "http://svc/things?tag=a",
+				Body:   &rpc.FixtureBody{Text: `{"name": "second"}`},
+			},
+			Response: rpc.FixtureResponse{Error: "connection refused"},
+		},
+	}
+
+	replay := rpc.NewReplayClient(fixtures, rpc.MatchMethod, rpc.MatchPath, rpc.MatchQuery, rpc.MatchBody)
+
+	req, err := replay.CreateRequest(http.MethodPost, "http://svc/things?tag=a", map[string]string{"name": "second"})
+	assert.NoError(err)
+	_, err = replay.Do(req)
+	assert.True(rpc.HasStatus(err, http.StatusServiceUnavailable))
+	assert.Contains(err.Error(), "connection refused")
+
+	req, err = replay.CreateRequest(http.MethodPost, "http://svc/things?tag=b", map[string]interface{}{"size": 1, "name": "first"})
+	assert.NoError(err)
+	_, err = replay.Do(req)
+	assert.True(rpc.HasStatus(err, http.StatusNotFound))
+	assert.Contains(err.Error(), `with body {"name":"first","size":1}`)
+	assert.Contains(err.Error(), "POST http://svc/things?tag=a")
+
+	req, err = replay.CreateRequest(http.MethodPost, "http://svc/things?tag=a", map[string]interface{}{"size": 1, "name": "first"})
+	assert.NoError(err)
+	res, err := replay.Do(req)
+	assert.NoError(err)
+	assert.Equal(http.StatusNoContent, res.StatusCode)
+
+	err = replay.ExpectationsWereMet()
+	assert.Error(err)
+	assert.Contains(err.Error(), "unexpected request POST http://svc/things?tag=b")
+}
@@ -1,14 +1,14 @@
 package rpc
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"net/http"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/CzarSimon/httputil"
@@ -25,6 +25,7 @@ const (
 	contentTypeText = "text/plain"
 
 	headerContentType = "Content-Type"
+	headerRetryAfter  = "Retry-After"
 )
 
 // HasStatus check if an error is a HTTPError with the specified status.
@@ -40,35 +41,68 @@ type Client interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
-// NewClient creates a new rpc client using the default implementation.
+// NewClient creates a new rpc client using the default implementation and the default codecs.
 func NewClient(timeout time.Duration) Client {
+	return NewClientWithCodecs(timeout, defaultCodecs)
+}
+
+// NewClientWithCodecs creates a new rpc client encoding request bodies with the supplied codecs
+// and accepting responses of the content types they decode.
+func NewClientWithCodecs(timeout time.Duration, codecs *Codecs) Client {
 	return &httpClient{
 		http: &http.Client{
 			Timeout: timeout,
 		},
+		codecs: codecs,
 	}
 }
 
 type httpClient struct {
-	http *http.Client
+	http   *http.Client
+	codecs *Codecs
 }
 
 func (c *httpClient) CreateRequest(method, url string, body interface{}) (*http.Request, error) {
-	r, err := createBody(body)
+	stream, ok := asStream(body)
+	if ok {
+		req, err := newStreamRequest(method, url, stream)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set(headerAccept, c.codecs.Accept())
+		return req, nil
+	}
+
+	r, contentType, err := c.createBody(body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request body\n%w", err)
 	}
 
 	req, err := http.NewRequest(method, url, r)
 	if err != nil {
+		if closer, ok := r.(io.Closer); ok {
+			closer.Close()
+		}
 		return nil, err
 	}
 
+	if contentType != "" {
+		req.Header.Set(headerContentType, contentType)
+	}
+	req.Header.Set(headerAccept, c.codecs.Accept())
+
 	return req, nil
 }
 
+// Do performs a request. The codecs of the client are carried by the request of the response, so that
+// Decode decodes the response with them.
 func (c *httpClient) Do(req *http.Request) (*http.Response, error) {
+	req = req.WithContext(context.WithValue(req.Context(), codecsContextKey{}, c.codecs))
 	res, err := c.http.Do(req)
+	if res != nil {
+		recordRawResponse(req, res)
+	}
 	err = wrapRequestError(req, res, err)
 	if err != nil {
 		return nil, err
@@ -95,54 +129,107 @@ func wrapRequestError(req *http.Request, res *http.Response, err error) error {
 		httpErr.Message = fmt.Sprintf("request failed, status: %s", res.Status)
 	}
 
-	if httpErr.Status >= 300 {
+	if httpErr.Status < 300 || httpErr.Status == http.StatusNotModified {
+		return err
+	}
+	if res == nil {
 		return httpErr
 	}
 
-	return err
+	wrapRemoteError(httpErr, res)
+	delay, ok := parseRetryAfter(res)
+	if ok {
+		httpErr.Err = &retryAfterError{delay: delay, err: httpErr.Err}
+	}
+
+	return httpErr
 }
 
-// DecodeJSON decodes a json response body into a value reciever.
-func DecodeJSON(res *http.Response, v interface{}) error {
-	contentType := res.Header.Get(headerContentType)
-	if !strings.HasPrefix(contentType, contentTypeJSON) && contentType == "" {
-		return fmt.Errorf("%w: %s", ErrUnsupportedContentType, contentType)
+// wrapRemoteError sets the error reported in the body of a failed response as the cause of an error and closes the body.
+func wrapRemoteError(httpErr *httputil.Error, res *http.Response) {
+	defer res.Body.Close()
+	remoteErr, ok := decodeRemoteError(res)
+	if ok && httpErr.Err == nil {
+		httpErr.Err = remoteErr
 	}
 
-	err := json.NewDecoder(res.Body).Decode(v)
-	if err != nil {
-		return fmt.Errorf("failed to parse response body\n%w", err)
+	io.Copy(ioutil.Discard, res.Body)
+}
+
+// retryAfterError cause of a failed response carrying the delay the remote service asked for with Retry-After.
+// The delay is kept in the cause rather than as an extension, so it never leaks into responses rendered from the error.
+type retryAfterError struct {
+	delay time.Duration
+	err   error
+}
+
+func (e *retryAfterError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("retry after %s", e.delay)
 	}
 
-	return nil
+	return fmt.Sprintf("retry after %s: %v", e.delay, e.err)
 }
 
-// DecodeText decodes a text/plain response body and returns it as a string.
-func DecodeText(res *http.Response) (string, error) {
-	contentType := res.Header.Get(headerContentType)
-	if !strings.HasPrefix(contentType, contentTypeText) && contentType == "" {
-		return "", fmt.Errorf("%w: %s", ErrUnsupportedContentType, contentType)
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
+
+// RetryAfter returns the delay a remote service asked for in the Retry-After header of a failed response.
+func RetryAfter(err error) (time.Duration, bool) {
+	var retryErr *retryAfterError
+	if !errors.As(err, &retryErr) {
+		return 0, false
+	}
+
+	return retryErr.delay, true
+}
+
+// parseRetryAfter parses the Retry-After header of a response, given either as seconds or a HTTP date.
+func parseRetryAfter(res *http.Response) (time.Duration, bool) {
+	value := res.Header.Get(headerRetryAfter)
+	if value == "" {
+		return 0, false
 	}
 
-	body, err := ioutil.ReadAll(res.Body)
+	seconds, convErr := strconv.Atoi(value)
+	if convErr != nil {
+		date, parseErr := http.ParseTime(value)
+		if parseErr != nil {
+			return 0, false
+		}
+		seconds = int(math.Ceil(time.Until(date).Seconds()))
+	}
+
+	if seconds < 0 {
+		seconds = 0
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// DecodeJSON decodes a json response body into a value reciever.
+// Returns ErrUnsupportedContentType unless the response content type is application/json or a +json type,
+// e.g. application/problem+json.
+func DecodeJSON(res *http.Response, v interface{}) error {
+	return decodeWith(jsonCodec{}, res, v)
+}
+
+// DecodeText decodes a text/plain response body and returns it as a string.
+// Returns ErrUnsupportedContentType unless the response content type is text/plain.
+func DecodeText(res *http.Response) (string, error) {
+	var text string
+	err := decodeWith(textCodec{}, res, &text)
 	if err != nil {
 		return "", err
 	}
 
-	return string(body), nil
+	return text, nil
 }
 
-func createBody(body interface{}) (io.Reader, error) {
+func (c *httpClient) createBody(body interface{}) (io.Reader, string, error) {
 	if body == nil {
-		return nil, nil
-	}
-
-	var bodyReader io.Reader
-	bytesBody, err := json.Marshal(body)
-	if err != nil {
-		return nil, err
+		return nil, "", nil
 	}
-	bodyReader = bytes.NewBuffer(bytesBody)
 
-	return bodyReader, nil
+	return c.codecs.Encode(body)
 }
@@ -0,0 +1,162 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// ContentTypeNDJSON content type of newline delimited JSON.
+const ContentTypeNDJSON = "application/x-ndjson"
+
+// Stream request body streamed from a reader without buffering. If the length is known it is sent
+// as the content length, otherwise the body is sent with chunked transfer encoding.
+// Streamed bodies can only be read once, so requests with them are never retried.
+type Stream struct {
+	Body        io.Reader
+	Length      int64
+	ContentType string
+}
+
+// IsStream checks if a request body is streamed from a reader, and thus cannot be replayed.
+func IsStream(body interface{}) bool {
+	switch body.(type) {
+	case Stream, *Stream, io.Reader, MultipartForm, *MultipartForm:
+		return true
+	default:
+		return false
+	}
+}
+
+func asStream(body interface{}) (Stream, bool) {
+	switch stream := body.(type) {
+	case Stream:
+		return stream, true
+	case *Stream:
+		if stream == nil {
+			return Stream{}, false
+		}
+		return *stream, true
+	default:
+		return Stream{}, false
+	}
+}
+
+// newStreamRequest creates a request streaming its body.
+func newStreamRequest(method, url string, stream Stream) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, stream.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.ContentLength = stream.Length
+	if stream.Length <= 0 {
+		req.ContentLength = -1
+	}
+
+	contentType := stream.ContentType
+	if contentType == "" {
+		contentType = ContentTypeBytes
+	}
+	req.Header.Set(headerContentType, contentType)
+	return req, nil
+}
+
+// JSONStream iterates over the elements of a JSON array or newline delimited JSON response body
+// without reading the whole body into memory. Use it like:
+//
+//	items, err := rpc.NewJSONStream(res)
+//	for items.Next() {
+//		var item Item
+//		err = items.Decode(&item)
+//	}
+//	err = items.Err()
+type JSONStream struct {
+	body    io.ReadCloser
+	dec     *json.Decoder
+	array   bool
+	started bool
+	done    bool
+	err     error
+}
+
+// NewJSONStream creates a JSONStream over a response body of the content type application/json,
+// holding a JSON array, or application/x-ndjson. Closing the stream closes the response body.
+func NewJSONStream(res *http.Response) (*JSONStream, error) {
+	contentType := res.Header.Get(headerContentType)
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || (mediaType != ContentTypeJSON && mediaType != ContentTypeNDJSON) {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedContentType, contentType)
+	}
+
+	return &JSONStream{
+		body:  res.Body,
+		dec:   json.NewDecoder(res.Body),
+		array: mediaType == ContentTypeJSON,
+	}, nil
+}
+
+// Next reports whether there is another element to decode.
+func (s *JSONStream) Next() bool {
+	if s.done || s.err != nil {
+		return false
+	}
+
+	if !s.started {
+		s.started = true
+		if s.array && !s.expectDelim('[') {
+			return false
+		}
+	}
+
+	if s.dec.More() {
+		return true
+	}
+
+	s.done = true
+	if s.array {
+		s.expectDelim(']')
+	}
+
+	return false
+}
+
+// Decode decodes the current element into v.
+func (s *JSONStream) Decode(v interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	err := s.dec.Decode(v)
+	if err != nil {
+		s.err = fmt.Errorf("failed to decode stream element: %w", err)
+	}
+
+	return s.err
+}
+
+// Err returns the first error encountered while iterating.
+func (s *JSONStream) Err() error {
+	return s.err
+}
+
+// Close closes the underlying response body.
+func (s *JSONStream) Close() error {
+	return s.body.Close()
+}
+
+func (s *JSONStream) expectDelim(delim json.Delim) bool {
+	token, err := s.dec.Token()
+	if err != nil {
+		s.err = fmt.Errorf("failed to read stream: %w", err)
+		return false
+	}
+	if token != delim {
+		s.err = fmt.Errorf("failed to read stream: expected %s got %v", delim, token)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,139 @@
+package rpc_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/httputil/client/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamRequest(t *testing.T) {
+	assert := assert.New(t)
+	var contentLength int64
+	var transferEncoding []string
+	var contentType string
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentLength = r.ContentLength
+		transferEncoding = r.TransferEncoding
+		contentType = r.Header.Get("Content-Type")
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := rpc.NewClient(time.Second)
+
+	req, err := client.CreateRequest(http.MethodPut, server.URL, rpc.Stream{
+		Body:        strings.NewReader("known length"),
+		Length:      12,
+		ContentType: "text/csv",
+	})
+	assert.NoError(err)
+	res, err := client.Do(req)
+	assert.NoError(err)
+	res.Body.Close()
+	assert.Equal(int64(12), contentLength)
+	assert.Empty(transferEncoding)
+	assert.Equal("text/csv", contentType)
+	assert.Equal("known length", body)
+
+	req, err = client.CreateRequest(http.MethodPut, server.URL, &rpc.Stream{
+		Body: ioutil.NopCloser(strings.NewReader("unknown length")),
+	})
+	assert.NoError(err)
+	res, err = client.Do(req)
+	assert.NoError(err)
+	res.Body.Close()
+	assert.Equal(int64(-1), contentLength)
+	assert.Equal([]string{"chunked"}, transferEncoding)
+	assert.Equal(rpc.ContentTypeBytes, contentType)
+	assert.Equal("unknown length", body)
+
+	assert.True(rpc.IsStream(rpc.Stream{}))
+	assert.True(rpc.IsStream(strings.NewReader("")))
+	assert.True(rpc.IsStream(rpc.MultipartForm{}))
+	assert.False(rpc.IsStream(map[string]string{}))
+	assert.False(rpc.IsStream(nil))
+}
+
+type item struct {
+	ID int `json:"id"`
+}
+
+func TestJSONStream(t *testing.T) {
+	assert := assert.New(t)
+
+	tt := []struct {
+		contentType string
+		body        string
+		ids         []int
+		err         bool
+	}{
+		{
+			contentType: "application/json",
+			body:        `[{"id": 1}, {"id": 2}, {"id": 3}]`,
+			ids:         []int{1, 2, 3},
+		},
+		{
+			contentType: "application/json; charset=utf-8",
+			body:        `[]`,
+			ids:         []int{},
+		},
+		{
+			contentType: "application/x-ndjson",
+			body:        "{\"id\": 1}\n{\"id\": 2}\n",
+			ids:         []int{1, 2},
+		},
+		{
+			contentType: "application/json",
+			body:        `{"id": 1}`,
+			ids:         []int{},
+			err:         true,
+		},
+		{
+			contentType: "application/json",
+			body:        `[{"id": 1}, {"id": `,
+			ids:         []int{1},
+			err:         true,
+		},
+	}
+
+	for i, tc := range tt {
+		res := &http.Response{
+			Header: http.Header{"Content-Type": []string{tc.contentType}},
+			Body:   ioutil.NopCloser(strings.NewReader(tc.body)),
+		}
+
+		stream, err := rpc.NewJSONStream(res)
+		assert.NoError(err, "Test %d", i)
+
+		ids := make([]int, 0)
+		for stream.Next() {
+			var v item
+			err = stream.Decode(&v)
+			if err != nil {
+				break
+			}
+			ids = append(ids, v.ID)
+		}
+
+		assert.Equal(tc.ids, ids, "Test %d", i)
+		assert.Equal(tc.err, stream.Err() != nil, "Test %d", i)
+		assert.NoError(stream.Close(), "Test %d", i)
+	}
+
+	res := &http.Response{
+		Header: http.Header{"Content-Type": []string{"text/plain"}},
+		Body:   ioutil.NopCloser(strings.NewReader("")),
+	}
+	_, err := rpc.NewJSONStream(res)
+	assert.True(errors.Is(err, rpc.ErrUnsupportedContentType))
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/httputil/client/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStream(t *testing.T) {
+	assert := assert.New(t)
+	var uploads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/upload":
+			atomic.AddInt32(&uploads, 1)
+			ioutil.ReadAll(r.Body)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/items":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			enc := json.NewEncoder(w)
+			for i := 1; i <= 3; i++ {
+				enc.Encode(map[string]int{"id": i})
+			}
+		case "/file":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte("file contents"))
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{
+		BaseURL:   server.URL,
+		RPCClient: rpc.NewClient(time.Second),
+		Retry: RetryPolicy{
+			MaxAttempts:       3,
+			RetryableStatuses: []int{http.StatusServiceUnavailable},
+		},
+	}
+	ctx := context.Background()
+
+	err := c.Stream(ctx, http.MethodPut, "/upload", rpc.Stream{Body: strings.NewReader("data")}, func(res *http.Response) error {
+		return nil
+	})
+	assert.True(rpc.HasStatus(err, http.StatusServiceUnavailable))
+	assert.Equal(int32(1), atomic.LoadInt32(&uploads))
+
+	ids := make([]int, 0)
+	err = c.Stream(ctx, http.MethodGet, "/items", nil, func(res *http.Response) error {
+		items, err := rpc.NewJSONStream(res)
+		if err != nil {
+			return err
+		}
+
+		for items.Next() {
+			var item map[string]int
+			err = items.Decode(&item)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, item["id"])
+		}
+
+		return items.Err()
+	})
+	assert.NoError(err)
+	assert.Equal([]int{1, 2, 3}, ids)
+
+	var buf bytes.Buffer
+	n, err := c.Download(ctx, "/file", &buf)
+	assert.NoError(err)
+	assert.Equal(int64(13), n)
+	assert.Equal("file contents", buf.String())
+}
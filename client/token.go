@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/CzarSimon/httputil/jwt"
+	"go.uber.org/zap"
+)
+
+// DefaultTokenLifetime default lifetime of service tokens minted by the client.
+const DefaultTokenLifetime = 5 * time.Minute
+
+type tokenContextKey struct{}
+
+// TokenSource provides the token used to authenticate requests.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc function implementing the TokenSource interface.
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+// Token calls f(ctx).
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// StaticToken creates a TokenSource always returning the same token.
+func StaticToken(token string) TokenSource {
+	return TokenSourceFunc(func(ctx context.Context) (string, error) {
+		return token, nil
+	})
+}
+
+// WithToken returns a context that makes requests carry the supplied token, such as the token of
+// the end user a request is made on behalf of, instead of the token of the client.
+func WithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenContextKey{}, token)
+}
+
+// TokenFromContext returns the token set on a context with WithToken.
+func TokenFromContext(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(tokenContextKey{}).(string)
+	return token, ok && token != ""
+}
+
+// IssuingTokenSource TokenSource minting tokens with an issuer and caching them until close to expiry.
+// Once a fifth of the token lifetime remains a new token is issued in the background while concurrent
+// callers keep using the cached token. Callers only block if the cached token is about to expire.
+type IssuingTokenSource struct {
+	issuer   jwt.Issuer
+	user     jwt.User
+	lifetime time.Duration
+	audience []string
+	now      func() time.Time
+
+	mu         sync.Mutex
+	token      string
+	expiresAt  time.Time
+	refreshing bool
+}
+
+// NewIssuingTokenSource creates a new IssuingTokenSource issuing tokens for a user with the given lifetime and audience.
+func NewIssuingTokenSource(issuer jwt.Issuer, user jwt.User, lifetime time.Duration, audience ...string) *IssuingTokenSource {
+	return &IssuingTokenSource{
+		issuer:   issuer,
+		user:     user,
+		lifetime: lifetime,
+		audience: audience,
+		now:      time.Now,
+	}
+}
+
+// Token returns the cached token, issuing a new one if it is missing or about to expire.
+func (s *IssuingTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.token == "" || now.After(s.expiresAt.Add(-s.lifetime/20)) {
+		return s.issue(now)
+	}
+
+	if now.After(s.expiresAt.Add(-s.lifetime/5)) && !s.refreshing {
+		s.refreshing = true
+		go s.refresh()
+	}
+
+	return s.token, nil
+}
+
+// refresh issues a new token without holding the lock, so that concurrent callers are not blocked while signing.
+func (s *IssuingTokenSource) refresh() {
+	issuedAt := s.now()
+	token, err := s.issuer.Issue(s.user, s.lifetime, s.audience...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshing = false
+	if err != nil {
+		log.Warn("failed to refresh auth token", zap.Error(err))
+		return
+	}
+
+	s.token = token
+	s.expiresAt = issuedAt.Add(s.lifetime)
+}
+
+func (s *IssuingTokenSource) issue(now time.Time) (string, error) {
+	token, err := s.issuer.Issue(s.user, s.lifetime, s.audience...)
+	if err != nil {
+		return "", fmt.Errorf("failed to issue auth token: %w", err)
+	}
+
+	s.token = token
+	s.expiresAt = now.Add(s.lifetime)
+	return token, nil
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/httputil/client/rpc"
+	"github.com/CzarSimon/httputil/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIssuingTokenSource(t *testing.T) {
+	assert := assert.New(t)
+	issuer := &countingIssuer{}
+	user := jwt.User{ID: "user-agent", Roles: []string{jwt.SystemRole}}
+	tokens := NewIssuingTokenSource(issuer, user, 10*time.Minute, "user-service")
+
+	now := time.Now()
+	tokens.now = func() time.Time {
+		return now
+	}
+
+	first, err := tokens.Token(context.Background())
+	assert.NoError(err)
+	assert.Equal("token-1", first)
+	assert.Equal([]string{"user-service"}, issuer.lastAudience())
+
+	now = now.Add(5 * time.Minute)
+	token, err := tokens.Token(context.Background())
+	assert.NoError(err)
+	assert.Equal(first, token)
+	assert.Equal(1, issuer.count())
+
+	now = now.Add(4 * time.Minute)
+	token, err = tokens.Token(context.Background())
+	assert.NoError(err)
+	assert.Equal(first, token)
+	assert.Eventually(func() bool {
+		token, _ := tokens.Token(context.Background())
+		return token == "token-2"
+	}, time.Second, 10*time.Millisecond)
+
+	now = now.Add(20 * time.Minute)
+	token, err = tokens.Token(context.Background())
+	assert.NoError(err)
+	assert.Equal("token-3", token)
+}
+
+func TestClientTokens(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"authorization": r.Header.Get("Authorization"),
+		})
+	}))
+	defer server.Close()
+
+	issuer := &countingIssuer{}
+	c := &Client{
+		Issuer:    issuer,
+		BaseURL:   server.URL,
+		Role:      jwt.SystemRole,
+		UserAgent: "client-test",
+		RPCClient: rpc.NewClient(time.Second),
+	}
+
+	var body map[string]string
+	for i := 0; i < 3; i++ {
+		err := c.Get(context.Background(), "/test", &body)
+		assert.NoError(err)
+		assert.Equal("Bearer token-1", body["authorization"])
+	}
+	assert.Equal(1, issuer.count())
+	assert.Equal([]string{"127.0.0.1"}, issuer.lastAudience())
+
+	copied := func(c Client) error {
+		return c.Get(context.Background(), "/test", &body)
+	}
+	assert.NoError(copied(*c))
+	assert.Equal("Bearer token-1", body["authorization"])
+	assert.Equal(1, issuer.count())
+
+	other := *c
+	other.Audience = "other-service"
+	assert.NoError(other.Get(context.Background(), "/test", &body))
+	assert.Equal("Bearer token-2", body["authorization"])
+	assert.Equal([]string{"other-service"}, issuer.lastAudience())
+	assert.NoError(c.Get(context.Background(), "/test", &body))
+	assert.Equal("Bearer token-1", body["authorization"])
+	assert.Equal(2, issuer.count())
+
+	ctx := WithToken(context.Background(), "end-user-token")
+	err := c.Get(ctx, "/test", &body)
+	assert.NoError(err)
+	assert.Equal("Bearer end-user-token", body["authorization"])
+
+	c = &Client{
+		BaseURL:     server.URL,
+		RPCClient:   rpc.NewClient(time.Second),
+		TokenSource: StaticToken("static-token"),
+	}
+	err = c.Get(context.Background(), "/test", &body)
+	assert.NoError(err)
+	assert.Equal("Bearer static-token", body["authorization"])
+}
+
+type countingIssuer struct {
+	mu       sync.Mutex
+	issued   int
+	audience []string
+}
+
+func (i *countingIssuer) Issue(user jwt.User, lifetime time.Duration, audience ...string) (string, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.issued++
+	i.audience = audience
+	return fmt.Sprintf("token-%d", i.issued), nil
+}
+
+func (i *countingIssuer) count() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.issued
+}
+
+func (i *countingIssuer) lastAudience() []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.audience
+}
@@ -15,17 +15,26 @@ import (
 
 var errLog = logger.GetDefaultLogger("httputil/error-log")
 
+const errorConfigKey = "httputil/error-config"
+
 // Error error containing status code and error.
 type Error struct {
-	ID      string `json:"id,omitempty"`
-	Status  int    `json:"status,omitempty"`
-	Message string `json:"message,omitempty"`
-	Err     error  `json:"-"`
+	ID         string                 `json:"id,omitempty"`
+	Code       string                 `json:"code,omitempty"`
+	Status     int                    `json:"status,omitempty"`
+	Message    string                 `json:"message,omitempty"`
+	Violations []FieldViolation       `json:"violations,omitempty"`
+	Err        error                  `json:"-"`
+	Extensions map[string]interface{} `json:"-"`
+	stack      []uintptr
 }
 
 // Error retruns a string representation of an Error and
 // makes the type compliant with the go error interface.
 func (err *Error) Error() string {
+	if err.Code != "" {
+		return fmt.Sprintf("Error(id=%s, code=%s, message=%s, status=%d, err=%v)", err.ID, err.Code, err.Message, err.Status, err.Err)
+	}
 	return fmt.Sprintf("Error(id=%s, message=%s, status=%d, err=%v)", err.ID, err.Message, err.Status, err.Err)
 }
 
@@ -34,10 +43,48 @@ func (err *Error) Unwrap() error {
 	return err.Err
 }
 
+// WithExtension adds an extension member, rendered in problem+json responses.
+func (err *Error) WithExtension(key string, value interface{}) *Error {
+	if err.Extensions == nil {
+		err.Extensions = make(map[string]interface{})
+	}
+
+	err.Extensions[key] = value
+	return err
+}
+
+// ErrorOption option to configure how HandleErrors renders errors.
+type ErrorOption func(*errorConfig)
+
+// ProblemJSON renders errors as RFC 7807 application/problem+json documents
+// instead of the default {id,status,message} shape.
+func ProblemJSON() ErrorOption {
+	return func(cfg *errorConfig) {
+		cfg.problemJSON = true
+	}
+}
+
+type errorConfig struct {
+	problemJSON        bool
+	sanitize           bool
+	allowedMessages    map[string]bool
+	allowCodedMessages bool
+	debug              bool
+}
+
+var defaultErrorConfig = &errorConfig{}
+
 // HandleErrors wrapper function to deal with encountered errors
-// during request handling.
-func HandleErrors() gin.HandlerFunc {
+// during request handling. The options also apply to errors rendered by
+// subsequent middlewares, such as AllowContentType and RBAC.Secure.
+func HandleErrors(opts ...ErrorOption) gin.HandlerFunc {
+	cfg := &errorConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
 	return func(c *gin.Context) {
+		c.Set(errorConfigKey, cfg)
 		c.Next()
 
 		err := getFirstError(c)
@@ -45,9 +92,76 @@ func HandleErrors() gin.HandlerFunc {
 			return
 		}
 
-		logError(c, err)
-		c.AbortWithStatusJSON(err.Status, err)
+		abortWithError(c, err)
+	}
+}
+
+// abortWithError logs an error and renders it according to the error config of the request.
+func abortWithError(c *gin.Context, err *Error) {
+	logError(c, err)
+
+	cfg := getErrorConfig(c)
+	if !cfg.problemJSON {
+		c.AbortWithStatusJSON(err.Status, cfg.body(err))
+		return
+	}
+
+	c.Header("Content-Type", MIMEProblemJSON)
+	c.AbortWithStatusJSON(err.Status, cfg.problem(err))
+}
+
+func (cfg *errorConfig) body(err *Error) interface{} {
+	sanitized := cfg.sanitizeError(err)
+	if !cfg.debug {
+		return sanitized
+	}
+
+	return debugError{
+		Error: sanitized,
+		Cause: errorChain(err),
+		Stack: err.StackTrace(),
+	}
+}
+
+func (cfg *errorConfig) problem(err *Error) Problem {
+	p := NewProblem(cfg.sanitizeError(err))
+	if !cfg.debug {
+		return p
+	}
+
+	extensions := make(map[string]interface{}, len(p.Extensions)+2)
+	for key, value := range p.Extensions {
+		extensions[key] = value
+	}
+	setIfMissing(extensions, "cause", errorChain(err))
+	if stack := err.StackTrace(); stack != "" {
+		setIfMissing(extensions, "stack", stack)
+	}
+	p.Extensions = extensions
+	return p
+}
+
+// setIfMissing sets a debug extension unless the error already has an extension with the same name.
+func setIfMissing(extensions map[string]interface{}, key string, value interface{}) {
+	if _, ok := extensions[key]; ok {
+		return
 	}
+
+	extensions[key] = value
+}
+
+func getErrorConfig(c *gin.Context) *errorConfig {
+	val, ok := c.Get(errorConfigKey)
+	if !ok {
+		return defaultErrorConfig
+	}
+
+	cfg, ok := val.(*errorConfig)
+	if !ok {
+		return defaultErrorConfig
+	}
+
+	return cfg
 }
 
 // getFirstError returns the first error in the gin.Context, nil if not present.
@@ -76,19 +190,26 @@ func logError(c *gin.Context, err *Error) {
 	if span != nil {
 		span.LogFields(tracelog.Error(err))
 		ext.HTTPStatusCode.Set(span, uint16(err.Status))
+		if err.Status >= 500 {
+			ext.Error.Set(span, true)
+			span.LogFields(tracelog.String("stack", err.StackTrace()))
+		}
 	}
 
 	if err.Status < 500 {
 		errLog.Info(err.Message,
 			zap.Int("status", err.Status),
 			zap.String("errorId", err.ID),
+			zap.String("errorCode", err.Code),
 			zap.Error(err.Err))
 		return
 	}
 	errLog.Error(err.Message,
 		zap.Int("status", err.Status),
 		zap.String("errorId", err.ID),
-		zap.Error(err.Err))
+		zap.String("errorCode", err.Code),
+		zap.Error(err.Err),
+		zap.String("stack", err.StackTrace()))
 }
 
 // BadRequestError creates a 400 - Bad Request error.
@@ -233,6 +354,7 @@ func NewError(message string, status int, err error) *Error {
 		Status:  status,
 		Message: message,
 		Err:     err,
+		stack:   callers(status),
 	}
 }
 
@@ -243,5 +365,6 @@ func Errorf(status int, format string, a ...interface{}) *Error {
 		Status:  status,
 		Message: http.StatusText(status),
 		Err:     fmt.Errorf(format, a...),
+		stack:   callers(status),
 	}
 }
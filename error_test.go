@@ -1,10 +1,17 @@
 package httputil_test
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/CzarSimon/httputil"
+	"github.com/CzarSimon/httputil/jwt"
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -91,3 +98,181 @@ func TestNewError(t *testing.T) {
 	assert.Error(err)
 	assert.True(errors.Is(err, baseErr))
 }
+
+func TestHandleErrors_DefaultFormat(t *testing.T) {
+	assert := assert.New(t)
+	r := httputil.NewRouter("httputil-test", func() error {
+		return nil
+	})
+	r.GET("/test", func(c *gin.Context) {
+		c.Error(httputil.ConflictError(errors.New("email taken")).WithExtension("field", "email"))
+	})
+
+	req := createTestRequest("/test", http.MethodGet, "", nil)
+	res := performTestRequest(r, req)
+	assert.Equal(http.StatusConflict, res.Code)
+	assert.True(strings.HasPrefix(res.Header().Get("Content-Type"), "application/json"))
+
+	var body map[string]interface{}
+	err := json.NewDecoder(res.Body).Decode(&body)
+	assert.NoError(err)
+	assert.Len(body, 3)
+	assert.NotEmpty(body["id"])
+	assert.Equal(float64(http.StatusConflict), body["status"])
+	assert.Equal("Conflict", body["message"])
+}
+
+func TestHandleErrors_ProblemJSON(t *testing.T) {
+	assert := assert.New(t)
+	r := httputil.NewCustomRouter(func() error {
+		return nil
+	}, httputil.HandleErrors(httputil.ProblemJSON()))
+	rbac := httputil.NewRBAC(getTestJWTCredentials())
+	r.GET("/test", func(c *gin.Context) {
+		c.Error(httputil.ConflictError(errors.New("email taken")).WithExtension("field", "email"))
+	})
+	r.GET("/json", httputil.AllowJSON(), httputil.SendOK)
+	r.GET("/secure", rbac.Secure(jwt.AdminRole), httputil.SendOK)
+
+	req := createTestRequest("/test", http.MethodGet, "", nil)
+	res := performTestRequest(r, req)
+	assert.Equal(http.StatusConflict, res.Code)
+	assert.Equal(httputil.MIMEProblemJSON, res.Header().Get("Content-Type"))
+
+	var problem httputil.Problem
+	err := json.NewDecoder(res.Body).Decode(&problem)
+	assert.NoError(err)
+	assert.Equal("about:blank", problem.Type)
+	assert.Equal("Conflict", problem.Title)
+	assert.Equal(http.StatusConflict, problem.Status)
+	assert.Equal("Conflict", problem.Detail)
+	assert.NotEmpty(problem.Instance)
+	assert.Equal("email", problem.Extensions["field"])
+
+	req = createTestRequest("/json", http.MethodGet, "", nil)
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusUnsupportedMediaType, res.Code)
+	assert.Equal(httputil.MIMEProblemJSON, res.Header().Get("Content-Type"))
+
+	req = createTestRequest("/secure", http.MethodGet, "", nil)
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusUnauthorized, res.Code)
+	assert.Equal(httputil.MIMEProblemJSON, res.Header().Get("Content-Type"))
+}
+
+func TestHandleErrors_Sanitize(t *testing.T) {
+	assert := assert.New(t)
+	policy := httputil.SanitizePolicy{
+		AllowedMessages:    []string{"Email is already taken"},
+		AllowCodedMessages: true,
+	}
+	r := httputil.NewCustomRouter(func() error {
+		return nil
+	}, httputil.HandleErrors(httputil.Sanitize(policy)))
+	r.GET("/internal", func(c *gin.Context) {
+		err := httputil.NewError("password=secret", http.StatusInternalServerError, errors.New("db failed"))
+		err.Code = "DB_FAILED"
+		c.Error(err)
+	})
+	r.GET("/allowed", func(c *gin.Context) {
+		c.Error(httputil.NewError("Email is already taken", http.StatusConflict, nil))
+	})
+	r.GET("/not-allowed", func(c *gin.Context) {
+		c.Error(httputil.NewError("user 42 not in table users", http.StatusNotFound, nil))
+	})
+
+	req := createTestRequest("/internal", http.MethodGet, "", nil)
+	res := performTestRequest(r, req)
+	assert.Equal(http.StatusInternalServerError, res.Code)
+	var body map[string]interface{}
+	err := json.NewDecoder(res.Body).Decode(&body)
+	assert.NoError(err)
+	assert.Len(body, 3)
+	assert.NotEmpty(body["id"])
+	assert.Equal("Internal Server Error", body["message"])
+
+	req = createTestRequest("/allowed", http.MethodGet, "", nil)
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusConflict, res.Code)
+	var httpErr httputil.Error
+	err = json.NewDecoder(res.Body).Decode(&httpErr)
+	assert.NoError(err)
+	assert.Equal("Email is already taken", httpErr.Message)
+
+	req = createTestRequest("/not-allowed", http.MethodGet, "", nil)
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusNotFound, res.Code)
+	httpErr = httputil.Error{}
+	err = json.NewDecoder(res.Body).Decode(&httpErr)
+	assert.NoError(err)
+	assert.Equal("Not Found", httpErr.Message)
+}
+
+func TestHandleErrors_SanitizeDebug(t *testing.T) {
+	assert := assert.New(t)
+	os.Setenv(httputil.DebugErrorsEnvironmentVariable, "true")
+	defer os.Unsetenv(httputil.DebugErrorsEnvironmentVariable)
+
+	policy := httputil.DefaultSanitizePolicy()
+	assert.True(policy.Debug)
+
+	r := httputil.NewCustomRouter(func() error {
+		return nil
+	}, httputil.HandleErrors(httputil.Sanitize(policy)))
+	r.GET("/internal", func(c *gin.Context) {
+		baseErr := errors.New("connection refused")
+		c.Error(httputil.InternalServerError(fmt.Errorf("query failed: %w", baseErr)))
+	})
+
+	req := createTestRequest("/internal", http.MethodGet, "", nil)
+	res := performTestRequest(r, req)
+	assert.Equal(http.StatusInternalServerError, res.Code)
+
+	var body struct {
+		ID      string   `json:"id"`
+		Message string   `json:"message"`
+		Cause   []string `json:"cause"`
+		Stack   string   `json:"stack"`
+	}
+	err := json.NewDecoder(res.Body).Decode(&body)
+	assert.NoError(err)
+	assert.NotEmpty(body.ID)
+	assert.Equal("Internal Server Error", body.Message)
+	assert.Equal([]string{"query failed: connection refused", "connection refused"}, body.Cause)
+	assert.True(strings.HasPrefix(body.Stack, "github.com/CzarSimon/httputil_test.TestHandleErrors_SanitizeDebug"), body.Stack)
+}
+
+func TestHandleErrors_SanitizeDebugKeepsExtensions(t *testing.T) {
+	assert := assert.New(t)
+	r := httputil.NewCustomRouter(func() error {
+		return nil
+	}, httputil.HandleErrors(httputil.ProblemJSON(), httputil.Sanitize(httputil.SanitizePolicy{Debug: true})))
+	r.GET("/test", func(c *gin.Context) {
+		c.Error(httputil.ConflictError(errors.New("email taken")).WithExtension("cause", "duplicate-email"))
+	})
+
+	req := createTestRequest("/test", http.MethodGet, "", nil)
+	res := performTestRequest(r, req)
+	assert.Equal(http.StatusConflict, res.Code)
+
+	var problem httputil.Problem
+	err := json.NewDecoder(res.Body).Decode(&problem)
+	assert.NoError(err)
+	assert.Equal("duplicate-email", problem.Extensions["cause"])
+	assert.NotContains(problem.Extensions, "stack")
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	assert := assert.New(t)
+	httputil.Sanitize(httputil.SanitizePolicy{Debug: true})
+
+	baseErr := errors.New("base error")
+	assert.Empty(httputil.BadRequestError(baseErr).StackTrace())
+	assert.Empty(httputil.Errorf(http.StatusConflict, "conflict on %s", "thing").StackTrace())
+	assert.Empty(httputil.ErrorCode{Code: "invalid-input", Status: http.StatusUnprocessableEntity, Message: "Invalid input"}.New(baseErr).StackTrace())
+
+	stack := httputil.InternalServerError(baseErr).StackTrace()
+	assert.True(strings.HasPrefix(stack, "github.com/CzarSimon/httputil_test.TestErrorStackTrace"), stack)
+	assert.NotEmpty(httputil.BadGatewayf("upstream %s failed", "thing").StackTrace())
+	assert.NotEmpty(httputil.ErrorCode{Code: "db-down", Status: http.StatusServiceUnavailable, Message: "Database down"}.New(baseErr).StackTrace())
+}
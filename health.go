@@ -0,0 +1,284 @@
+package httputil
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Health probe paths.
+const (
+	livenessPath  = "/health/live"
+	readinessPath = "/health/ready"
+	startupPath   = "/health/startup"
+)
+
+// Health statuses.
+const (
+	StatusUp   = "UP"
+	StatusDown = "DOWN"
+)
+
+const (
+	defaultHealthCheckTimeout = 2 * time.Second
+	defaultHealthCheckTTL     = time.Second
+)
+
+// Health check errors.
+var (
+	ErrHealthCheckTimeout = errors.New("health check timed out")
+	ErrShuttingDown       = errors.New("shutting down")
+)
+
+// Probe kind of health probe, corresponds to the kubernetes probe types.
+type Probe string
+
+// Health probes.
+const (
+	LivenessProbe  Probe = "live"
+	ReadinessProbe Probe = "ready"
+	StartupProbe   Probe = "startup"
+)
+
+// HealthCheck named health check of a dependency.
+type HealthCheck struct {
+	// Name name of the check, included in the health report.
+	Name string
+	// Check function performing the check.
+	Check HealthFunc
+	// Timeout max duration to wait for the check. Defaults to 2 seconds.
+	Timeout time.Duration
+	// TTL duration for which a check result is cached. Defaults to 1 second.
+	TTL time.Duration
+	// Probes the probes the check is part of. Defaults to the readiness and startup probes.
+	Probes []Probe
+}
+
+// CheckResult outcome of a single health check.
+type CheckResult struct {
+	Name    string  `json:"name"`
+	Status  string  `json:"status"`
+	Latency float64 `json:"latency"`
+	Error   string  `json:"error,omitempty"`
+	err     error
+}
+
+// HealthReport result of running all health checks of a probe.
+type HealthReport struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// Healthy returns true if all checks in the report are up.
+func (r HealthReport) Healthy() bool {
+	return r.Status == StatusUp
+}
+
+// Err returns the error of the first failed check in the report, nil if the report is healthy.
+func (r HealthReport) Err() error {
+	for _, check := range r.Checks {
+		if check.err != nil {
+			return check.err
+		}
+	}
+
+	return nil
+}
+
+// HealthChecker keeps track of named health checks and runs them per probe.
+type HealthChecker struct {
+	mu           sync.RWMutex
+	checks       []*healthCheck
+	shuttingDown int32
+}
+
+// NewHealthChecker creates a new HealthChecker with the given checks registered.
+func NewHealthChecker(checks ...HealthCheck) *HealthChecker {
+	h := &HealthChecker{}
+	for _, check := range checks {
+		h.Register(check)
+	}
+
+	return h
+}
+
+// Register adds a named health check.
+func (h *HealthChecker) Register(check HealthCheck) {
+	if check.Timeout <= 0 {
+		check.Timeout = defaultHealthCheckTimeout
+	}
+	if check.TTL <= 0 {
+		check.TTL = defaultHealthCheckTTL
+	}
+	if len(check.Probes) == 0 {
+		check.Probes = []Probe{ReadinessProbe, StartupProbe}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, &healthCheck{HealthCheck: check})
+}
+
+// SetShuttingDown marks the service as shutting down, failing the readiness probe
+// so that no new traffic is routed to it.
+func (h *HealthChecker) SetShuttingDown() {
+	atomic.StoreInt32(&h.shuttingDown, 1)
+}
+
+// ShuttingDown returns true if the service has been marked as shutting down.
+func (h *HealthChecker) ShuttingDown() bool {
+	return atomic.LoadInt32(&h.shuttingDown) == 1
+}
+
+// Check runs all health checks registered for a probe.
+func (h *HealthChecker) Check(probe Probe) HealthReport {
+	checks := h.getChecks(probe)
+	results := make([]CheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check *healthCheck) {
+			defer wg.Done()
+			results[i] = check.run()
+		}(i, check)
+	}
+	wg.Wait()
+
+	if probe == ReadinessProbe && h.ShuttingDown() {
+		results = append(results, newCheckResult("shutdown", 0, ErrShuttingDown))
+	}
+
+	report := HealthReport{
+		Status: StatusUp,
+		Checks: results,
+	}
+	for _, result := range results {
+		if result.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+
+	return report
+}
+
+// Handler creates a handler reporting the result of a probe.
+func (h *HealthChecker) Handler(probe Probe) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := h.Check(probe)
+		if report.Healthy() {
+			c.JSON(http.StatusOK, report)
+			return
+		}
+
+		c.JSON(http.StatusServiceUnavailable, report)
+	}
+}
+
+func (h *HealthChecker) getChecks(probe Probe) []*healthCheck {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	checks := make([]*healthCheck, 0, len(h.checks))
+	for _, check := range h.checks {
+		if check.partOf(probe) {
+			checks = append(checks, check)
+		}
+	}
+
+	return checks
+}
+
+type healthCheck struct {
+	HealthCheck
+	mu        sync.Mutex
+	result    CheckResult
+	expiresAt time.Time
+	pending   chan struct{}
+}
+
+// run returns the cached result of the check if still valid. Otherwise it waits for
+// the check to complete, only ever running one execution of the check at a time.
+func (hc *healthCheck) run() CheckResult {
+	hc.mu.Lock()
+	if time.Now().Before(hc.expiresAt) {
+		result := hc.result
+		hc.mu.Unlock()
+		return result
+	}
+
+	if hc.pending == nil {
+		hc.pending = make(chan struct{})
+		go hc.execute(hc.pending)
+	}
+	pending := hc.pending
+	hc.mu.Unlock()
+
+	select {
+	case <-pending:
+		hc.mu.Lock()
+		defer hc.mu.Unlock()
+		return hc.result
+	case <-time.After(hc.Timeout):
+		err := fmt.Errorf("%w: %s after %s", ErrHealthCheckTimeout, hc.Name, hc.Timeout)
+		return newCheckResult(hc.Name, toMilliseconds(hc.Timeout), err)
+	}
+}
+
+// execute runs the check and stores its result. A panicking check is recorded as down.
+func (hc *healthCheck) execute(done chan struct{}) {
+	var err error
+	stop := createTimer()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("health check panicked: %v", r)
+		}
+		result := newCheckResult(hc.Name, stop(), err)
+
+		hc.mu.Lock()
+		hc.result = result
+		hc.expiresAt = time.Now().Add(hc.TTL)
+		hc.pending = nil
+		hc.mu.Unlock()
+		close(done)
+	}()
+
+	err = hc.Check()
+}
+
+func (hc *healthCheck) partOf(probe Probe) bool {
+	for _, p := range hc.Probes {
+		if p == probe {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newCheckResult(name string, latency float64, err error) CheckResult {
+	if err == nil {
+		return CheckResult{
+			Name:    name,
+			Status:  StatusUp,
+			Latency: latency,
+		}
+	}
+
+	return CheckResult{
+		Name:    name,
+		Status:  StatusDown,
+		Latency: latency,
+		Error:   err.Error(),
+		err:     err,
+	}
+}
+
+func toMilliseconds(d time.Duration) float64 {
+	return float64(d) / 1e6
+}
@@ -0,0 +1,142 @@
+package httputil_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/httputil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthProbes(t *testing.T) {
+	assert := assert.New(t)
+	dbErr := errors.New("db is down")
+	var dbHealthy atomic.Value
+	dbHealthy.Store(true)
+
+	health := httputil.NewHealthChecker(
+		httputil.HealthCheck{
+			Name: "db",
+			Check: func() error {
+				if dbHealthy.Load().(bool) {
+					return nil
+				}
+				return dbErr
+			},
+			TTL: time.Nanosecond,
+		},
+		httputil.HealthCheck{
+			Name:   "process",
+			Check:  func() error { return nil },
+			Probes: []httputil.Probe{httputil.LivenessProbe},
+		},
+	)
+	r := httputil.NewRouterWithHealth("httputil-test", health)
+
+	for _, path := range []string{"/health", "/health/live", "/health/ready", "/health/startup"} {
+		req := createTestRequest(path, http.MethodGet, "", nil)
+		res := performTestRequest(r, req)
+		assert.Equal(http.StatusOK, res.Code, path)
+	}
+
+	dbHealthy.Store(false)
+	time.Sleep(time.Millisecond)
+
+	req := createTestRequest("/health/live", http.MethodGet, "", nil)
+	res := performTestRequest(r, req)
+	assert.Equal(http.StatusOK, res.Code)
+	var report httputil.HealthReport
+	err := json.NewDecoder(res.Body).Decode(&report)
+	assert.NoError(err)
+	assert.Equal(httputil.StatusUp, report.Status)
+	assert.Len(report.Checks, 1)
+	assert.Equal("process", report.Checks[0].Name)
+
+	req = createTestRequest("/health/ready", http.MethodGet, "", nil)
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusServiceUnavailable, res.Code)
+	report = httputil.HealthReport{}
+	err = json.NewDecoder(res.Body).Decode(&report)
+	assert.NoError(err)
+	assert.Equal(httputil.StatusDown, report.Status)
+	assert.Len(report.Checks, 1)
+	assert.Equal("db", report.Checks[0].Name)
+	assert.Equal(httputil.StatusDown, report.Checks[0].Status)
+	assert.Equal(dbErr.Error(), report.Checks[0].Error)
+
+	req = createTestRequest("/health", http.MethodGet, "", nil)
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusInternalServerError, res.Code)
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	assert := assert.New(t)
+	release := make(chan struct{})
+	defer close(release)
+
+	var calls int32
+	health := httputil.NewHealthChecker(httputil.HealthCheck{
+		Name: "slow",
+		Check: func() error {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return nil
+		},
+		Timeout: 20 * time.Millisecond,
+	})
+
+	for i := 0; i < 3; i++ {
+		report := health.Check(httputil.ReadinessProbe)
+		assert.False(report.Healthy())
+		assert.True(errors.Is(report.Err(), httputil.ErrHealthCheckTimeout))
+		assert.Equal(httputil.StatusDown, report.Checks[0].Status)
+	}
+
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestHealthCheckCaching(t *testing.T) {
+	assert := assert.New(t)
+
+	var calls int32
+	health := httputil.NewHealthChecker(httputil.HealthCheck{
+		Name: "cached",
+		Check: func() error {
+			atomic.AddInt32(&calls, 1)
+			return nil
+		},
+		TTL: time.Minute,
+	})
+
+	for i := 0; i < 5; i++ {
+		report := health.Check(httputil.StartupProbe)
+		assert.True(report.Healthy())
+		assert.NoError(report.Err())
+	}
+
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestHealthCheckPanic(t *testing.T) {
+	assert := assert.New(t)
+	r := httputil.NewRouter("httputil-test", func() error {
+		panic("db driver bug")
+	})
+
+	req := createTestRequest("/health", http.MethodGet, "", nil)
+	res := performTestRequest(r, req)
+	assert.Equal(http.StatusInternalServerError, res.Code)
+
+	req = createTestRequest("/health/ready", http.MethodGet, "", nil)
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusServiceUnavailable, res.Code)
+	var report httputil.HealthReport
+	err := json.NewDecoder(res.Body).Decode(&report)
+	assert.NoError(err)
+	assert.Equal(httputil.StatusDown, report.Status)
+	assert.Equal("health check panicked: db driver bug", report.Checks[0].Error)
+}
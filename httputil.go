@@ -15,25 +15,42 @@ const (
 // HealthFunc health check function signature.
 type HealthFunc func() error
 
-// NewRouter creates a default router.
+// NewRouter creates a default router. The health check is run as a HealthCheck with the default
+// timeout and TTL, i.e. it fails after 2 seconds and its result is cached for 1 second.
+// Use NewRouterWithHealth to configure them.
 func NewRouter(appName string, healthCheck HealthFunc) *gin.Engine {
-	return NewCustomRouter(
-		healthCheck,
-		gin.Recovery(),
+	return NewRouterWithHealth(appName, healthCheckerFromFunc(healthCheck))
+}
+
+// NewRouterWithHealth creates a default router exposing the probes of a HealthChecker.
+func NewRouterWithHealth(appName string, health *HealthChecker) *gin.Engine {
+	return NewCustomRouterWithHealth(
+		health,
+		Recovery(),
 		RequestID(RequestIDHeader),
 		Trace(appName, RequestIDHeader, ClientIDHeader, SessionIDHeader),
 		Metrics(),
-		Logger(healthPath, metricsPath),
+		Logger(healthPath, livenessPath, readinessPath, startupPath, metricsPath),
 		HandleErrors(),
 	)
 }
 
 // NewCustomRouter creates a new router with a custom list of base middlewares.
+// The health check is run with the default timeout and TTL, see NewRouter.
 func NewCustomRouter(healthCheck HealthFunc, middlewares ...gin.HandlerFunc) *gin.Engine {
+	return NewCustomRouterWithHealth(healthCheckerFromFunc(healthCheck), middlewares...)
+}
+
+// NewCustomRouterWithHealth creates a new router with a custom list of base middlewares
+// exposing the probes of a HealthChecker.
+func NewCustomRouterWithHealth(health *HealthChecker, middlewares ...gin.HandlerFunc) *gin.Engine {
 	r := gin.New()
 	r.Use(middlewares...)
 
-	r.GET(healthPath, checkHealth(healthCheck))
+	r.GET(healthPath, checkHealth(health))
+	r.GET(livenessPath, health.Handler(LivenessProbe))
+	r.GET(readinessPath, health.Handler(ReadinessProbe))
+	r.GET(startupPath, health.Handler(StartupProbe))
 	r.GET(metricsPath, prometheusHandler())
 	return r
 }
@@ -78,8 +95,7 @@ func AllowContentType(types ...string) gin.HandlerFunc {
 		}
 
 		err := UnsupportedMediaTypeError(fmt.Errorf("unsupported content-type: %s", ct))
-		logError(c, err)
-		c.AbortWithStatusJSON(err.Status, err)
+		abortWithError(c, err)
 	}
 }
 
@@ -88,9 +104,9 @@ func AllowJSON() gin.HandlerFunc {
 	return AllowContentType(gin.MIMEJSON)
 }
 
-func checkHealth(check HealthFunc) gin.HandlerFunc {
+func checkHealth(health *HealthChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		err := check()
+		err := health.Check(ReadinessProbe).Err()
 		if err == nil {
 			SendOK(c)
 			return
@@ -99,3 +115,10 @@ func checkHealth(check HealthFunc) gin.HandlerFunc {
 		c.Error(err)
 	}
 }
+
+func healthCheckerFromFunc(check HealthFunc) *HealthChecker {
+	return NewHealthChecker(HealthCheck{
+		Name:  "default",
+		Check: check,
+	})
+}
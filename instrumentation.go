@@ -11,6 +11,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
+	tracelog "github.com/opentracing/opentracing-go/log"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -61,13 +62,15 @@ func Metrics() gin.HandlerFunc {
 		}
 		stop := createTimer()
 		endpoint := c.FullPath()
-		c.Next()
+		defer afterRequest(c, func(code int, recovered interface{}) {
+			status := strconv.Itoa(code)
+			method := c.Request.Method
+			latency := stop()
+			requestsTotal.WithLabelValues(endpoint, method, status).Inc()
+			requestsLatency.WithLabelValues(endpoint, method, status).Observe(latency)
+		})
 
-		status := strconv.Itoa(c.Writer.Status())
-		method := c.Request.Method
-		latency := stop()
-		requestsTotal.WithLabelValues(endpoint, method, status).Inc()
-		requestsLatency.WithLabelValues(endpoint, method, status).Observe(latency)
+		c.Next()
 	}
 }
 
@@ -103,10 +106,16 @@ func Trace(app string, headers ...string) gin.HandlerFunc {
 		}
 
 		c.Request = c.Request.WithContext(opentracing.ContextWithSpan(c.Request.Context(), span))
-		c.Next()
+		defer afterRequest(c, func(status int, recovered interface{}) {
+			if recovered != nil {
+				ext.Error.Set(span, true)
+				span.LogFields(tracelog.String("event", "panic"), tracelog.String("message", fmt.Sprint(recovered)))
+			}
+			ext.HTTPStatusCode.Set(span, uint16(status))
+			span.Finish()
+		})
 
-		ext.HTTPStatusCode.Set(span, uint16(c.Writer.Status()))
-		span.Finish()
+		c.Next()
 	}
 }
 
@@ -140,26 +149,42 @@ func Logger(skip ...string) gin.HandlerFunc {
 			)
 		}
 
-		c.Next()
+		defer afterRequest(c, func(status int, recovered interface{}) {
+			latency := stop()
+			if skippablePath && status < http.StatusInternalServerError {
+				return
+			}
 
-		latency := stop()
-		status := c.Writer.Status()
+			logFn := requestLog.Info
+			if status >= http.StatusInternalServerError {
+				logFn = requestLog.Error
+			}
 
-		if skippablePath && status < http.StatusInternalServerError {
-			return
-		}
+			logFn(
+				fmt.Sprintf("Outgoing request: %s %s", c.Request.Method, path),
+				zap.String("requestId", reqID),
+				zap.Int("status", status),
+				zap.Float64("latency", latency),
+			)
+		})
 
-		logFn := requestLog.Info
-		if status >= http.StatusInternalServerError {
-			logFn = requestLog.Error
-		}
+		c.Next()
+	}
+}
 
-		logFn(
-			fmt.Sprintf("Outgoing request: %s %s", c.Request.Method, path),
-			zap.String("requestId", reqID),
-			zap.Int("status", status),
-			zap.Float64("latency", latency),
-		)
+// afterRequest deferred by middlewares to call fn once the rest of the chain has returned or
+// is unwinding from a panic. The status of a panicking request, which has not written a response,
+// is reported as 500 - Internal Server Error. Panics are passed on to be handled by Recovery.
+func afterRequest(c *gin.Context, fn func(status int, recovered interface{})) {
+	recovered := recover()
+	status := c.Writer.Status()
+	if recovered != nil && !c.Writer.Written() {
+		status = http.StatusInternalServerError
+	}
+
+	fn(status, recovered)
+	if recovered != nil {
+		panic(recovered)
 	}
 }
 
@@ -0,0 +1,20 @@
+package httputil
+
+import (
+	"net/http"
+
+	"github.com/CzarSimon/httputil/jwt"
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSPath well known path to publish a JSON Web Key Set on.
+const JWKSPath = "/.well-known/jwks.json"
+
+// JWKS creates a handler publishing the public keys of a key set as a JSON Web Key Set.
+// Mount it on JWKSPath to let other services verify tokens using a remote verifier.
+func JWKS(keys *jwt.KeySet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, keys.PublicJWKS())
+	}
+}
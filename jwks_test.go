@@ -0,0 +1,117 @@
+package httputil_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/httputil"
+	"github.com/CzarSimon/httputil/jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWKSAndRemoteVerifier(t *testing.T) {
+	assert := assert.New(t)
+
+	_, firstKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(err)
+	_, secondKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(err)
+
+	keys, err := jwt.NewKeySet("key-1", firstKey)
+	assert.NoError(err)
+	issuer := jwt.NewKeySetIssuer("issuing-service", keys)
+
+	r := httputil.NewRouter("httputil-test", func() error {
+		return nil
+	})
+	r.GET(httputil.JWKSPath, httputil.JWKS(keys))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	remoteKeys := jwt.NewRemoteKeySource(server.URL+httputil.JWKSPath, time.Hour, 0)
+	verifier := jwt.NewKeySetVerifier("issuing-service", remoteKeys, time.Minute)
+	user := jwt.User{
+		ID:    "user-id",
+		Roles: []string{jwt.UserRole},
+	}
+
+	token, err := issuer.Issue(user, time.Hour)
+	assert.NoError(err)
+	verified, err := verifier.Verify(token)
+	assert.NoError(err)
+	assert.Equal(user.ID, verified.ID)
+
+	err = keys.Rotate("key-2", secondKey)
+	assert.NoError(err)
+	token, err = issuer.Issue(user, time.Hour)
+	assert.NoError(err)
+	verified, err = verifier.Verify(token)
+	assert.NoError(err)
+	assert.Equal(user.ID, verified.ID)
+
+	_, err = httputil.NewRBAC(jwt.Credentials{}).Verifier.Verify(token)
+	assert.Error(err)
+
+	res, err := http.Get(server.URL + httputil.JWKSPath)
+	assert.NoError(err)
+	defer res.Body.Close()
+	assert.Equal(http.StatusOK, res.StatusCode)
+
+	unreachable := jwt.NewRemoteVerifier("issuing-service", "http://127.0.0.1:1/jwks.json", time.Minute)
+	_, err = unreachable.Verify(token)
+	assert.Equal(jwt.ErrInvalidToken, err)
+}
+
+func TestRemoteKeySource_ServesCachedKeysWhenRefreshFails(t *testing.T) {
+	assert := assert.New(t)
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(err)
+	keys, err := jwt.NewKeySet("key-1", key)
+	assert.NoError(err)
+	issuer := jwt.NewKeySetIssuer("issuing-service", keys)
+
+	var requests int32
+	var down int32
+	jwks := httputil.JWKS(keys)
+	r := httputil.NewRouter("httputil-test", func() error {
+		return nil
+	})
+	r.GET(httputil.JWKSPath, func(c *gin.Context) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.LoadInt32(&down) == 1 {
+			c.AbortWithStatus(http.StatusServiceUnavailable)
+			return
+		}
+		jwks(c)
+	})
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	remoteKeys := jwt.NewRemoteKeySource(server.URL+httputil.JWKSPath, time.Millisecond, time.Hour)
+	verifier := jwt.NewKeySetVerifier("issuing-service", remoteKeys, time.Minute)
+	token, err := issuer.Issue(jwt.User{ID: "user-id", Roles: []string{jwt.UserRole}}, time.Hour)
+	assert.NoError(err)
+
+	_, err = verifier.Verify(token)
+	assert.NoError(err)
+	assert.Equal(int32(1), atomic.LoadInt32(&requests))
+
+	atomic.StoreInt32(&down, 1)
+	time.Sleep(5 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		_, err = verifier.Verify(token)
+		assert.NoError(err)
+	}
+
+	assert.Error(remoteKeys.Refresh())
+	_, err = verifier.Verify(token)
+	assert.NoError(err)
+	assert.Equal(int32(2), atomic.LoadInt32(&requests))
+}
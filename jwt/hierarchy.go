@@ -0,0 +1,151 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const hierarchyDelimiter = ">"
+
+// ErrRoleHierarchyCycle is returned when a role hierarchy contains a cycle.
+var ErrRoleHierarchyCycle = errors.New("role hierarchy contains a cycle")
+
+// RoleHierarchy describes which roles inherit other roles, e.g. SYSTEM > ADMIN > USER
+// where a user with the ADMIN role also has the USER role.
+type RoleHierarchy struct {
+	inherits map[string][]string
+	implied  map[string]map[string]bool
+}
+
+// NewRoleHierarchy creates a RoleHierarchy from a map of roles to the roles they directly inherit.
+// Returns an error if the hierarchy contains a cycle.
+func NewRoleHierarchy(inherits map[string][]string) (*RoleHierarchy, error) {
+	h := &RoleHierarchy{
+		inherits: make(map[string][]string, len(inherits)),
+		implied:  make(map[string]map[string]bool, len(inherits)),
+	}
+	for role, children := range inherits {
+		h.inherits[role] = append([]string{}, children...)
+	}
+
+	for role := range h.inherits {
+		err := h.resolve(role, []string{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return h, nil
+}
+
+// MustNewRoleHierarchy creates a RoleHierarchy and panics if it contains a cycle.
+func MustNewRoleHierarchy(inherits map[string][]string) *RoleHierarchy {
+	h, err := NewRoleHierarchy(inherits)
+	if err != nil {
+		log.Panic("Failed to create role hierarchy", zap.Error(err))
+	}
+
+	return h
+}
+
+// ParseRoleHierarchy creates a RoleHierarchy from chains of roles in descending order
+// of privilege, such as "SYSTEM > ADMIN > USER".
+func ParseRoleHierarchy(chains ...string) (*RoleHierarchy, error) {
+	inherits := make(map[string][]string)
+	for _, chain := range chains {
+		roles := strings.Split(chain, hierarchyDelimiter)
+		for i := 0; i < len(roles)-1; i++ {
+			parent := strings.TrimSpace(roles[i])
+			child := strings.TrimSpace(roles[i+1])
+			if parent == "" || child == "" {
+				return nil, fmt.Errorf("invalid role hierarchy: %s", chain)
+			}
+
+			inherits[parent] = append(inherits[parent], child)
+		}
+	}
+
+	return NewRoleHierarchy(inherits)
+}
+
+// DefaultRoleHierarchy creates the hierarchy SYSTEM > ADMIN > USER.
+func DefaultRoleHierarchy() *RoleHierarchy {
+	return MustNewRoleHierarchy(map[string][]string{
+		SystemRole: {AdminRole},
+		AdminRole:  {UserRole},
+	})
+}
+
+// Implies checks if a role is or inherits a candidate role.
+func (h *RoleHierarchy) Implies(role, candidate string) bool {
+	if role == candidate {
+		return true
+	}
+	if h == nil {
+		return false
+	}
+
+	return h.implied[role][candidate]
+}
+
+// Expand returns the sorted list of roles including all inherited roles.
+func (h *RoleHierarchy) Expand(roles ...string) []string {
+	unique := make(map[string]bool)
+	for _, role := range roles {
+		unique[role] = true
+		if h == nil {
+			continue
+		}
+		for inherited := range h.implied[role] {
+			unique[inherited] = true
+		}
+	}
+
+	expanded := make([]string, 0, len(unique))
+	for role := range unique {
+		expanded = append(expanded, role)
+	}
+
+	sort.Strings(expanded)
+	return expanded
+}
+
+// Apply returns a copy of the user whose role checks consult the hierarchy.
+func (h *RoleHierarchy) Apply(user User) User {
+	user.hierarchy = h
+	return user
+}
+
+// resolve computes the set of roles implied by a role, detecting cycles along the path.
+func (h *RoleHierarchy) resolve(role string, path []string) error {
+	for _, visited := range path {
+		if visited == role {
+			cycle := strings.Join(append(path, role), " "+hierarchyDelimiter+" ")
+			return fmt.Errorf("%w: %s", ErrRoleHierarchyCycle, cycle)
+		}
+	}
+
+	if _, ok := h.implied[role]; ok {
+		return nil
+	}
+
+	implied := make(map[string]bool)
+	for _, child := range h.inherits[role] {
+		err := h.resolve(child, append(path, role))
+		if err != nil {
+			return err
+		}
+
+		implied[child] = true
+		for inherited := range h.implied[child] {
+			implied[inherited] = true
+		}
+	}
+
+	h.implied[role] = implied
+	return nil
+}
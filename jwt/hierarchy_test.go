@@ -0,0 +1,76 @@
+package jwt_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/CzarSimon/httputil/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleHierarchy(t *testing.T) {
+	assert := assert.New(t)
+	hierarchy, err := jwt.ParseRoleHierarchy("SYSTEM > ADMIN > USER", "ADMIN > SUPPORT")
+	assert.NoError(err)
+
+	admin := hierarchy.Apply(jwt.User{
+		ID:    "admin-id",
+		Roles: []string{jwt.AdminRole},
+	})
+	assert.True(admin.HasRole(jwt.AdminRole))
+	assert.True(admin.HasRole(jwt.UserRole))
+	assert.True(admin.HasRole("SUPPORT"))
+	assert.False(admin.HasRole(jwt.SystemRole))
+	assert.False(admin.IsSystem())
+	assert.Equal([]string{jwt.AdminRole, "SUPPORT", jwt.UserRole}, admin.EffectiveRoles())
+
+	system := hierarchy.Apply(jwt.User{
+		ID:    "system-id",
+		Roles: []string{jwt.SystemRole},
+	})
+	assert.True(system.IsAdmin())
+	assert.True(system.HasRole(jwt.UserRole))
+
+	user := jwt.User{
+		ID:    "user-id",
+		Roles: []string{jwt.AdminRole},
+	}
+	assert.False(user.HasRole(jwt.UserRole))
+	assert.Equal([]string{jwt.AdminRole}, user.EffectiveRoles())
+
+	permissions := jwt.RolePermissions{
+		jwt.UserRole:  {"orders:read"},
+		jwt.AdminRole: {"orders:write"},
+	}
+	admin = permissions.Grant(admin)
+	assert.Equal([]string{"orders:read", "orders:write"}, admin.Permissions)
+}
+
+func TestRoleHierarchy_Cycles(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := jwt.ParseRoleHierarchy("SYSTEM > ADMIN > USER > SYSTEM")
+	assert.True(errors.Is(err, jwt.ErrRoleHierarchyCycle))
+
+	_, err = jwt.NewRoleHierarchy(map[string][]string{
+		"A": {"B"},
+		"B": {"C", "D"},
+		"D": {"B"},
+	})
+	assert.True(errors.Is(err, jwt.ErrRoleHierarchyCycle))
+
+	_, err = jwt.NewRoleHierarchy(map[string][]string{
+		"A": {"A"},
+	})
+	assert.True(errors.Is(err, jwt.ErrRoleHierarchyCycle))
+
+	_, err = jwt.ParseRoleHierarchy("SYSTEM > > USER")
+	assert.Error(err)
+
+	assert.NotPanics(func() {
+		jwt.DefaultRoleHierarchy()
+	})
+	assert.Panics(func() {
+		jwt.MustNewRoleHierarchy(map[string][]string{"A": {"B"}, "B": {"A"}})
+	})
+}
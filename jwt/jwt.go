@@ -1,11 +1,14 @@
 package jwt
 
 import (
+	"crypto"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/CzarSimon/httputil/id"
 	"github.com/CzarSimon/httputil/logger"
 	"go.uber.org/zap"
 	"gopkg.in/square/go-jose.v2"
@@ -20,6 +23,7 @@ const roleDelimiter = ";"
 const (
 	SystemRole    = "SYSTEM"
 	AdminRole     = "ADMIN"
+	UserRole      = "USER"
 	AnonymousRole = "ANONYMOUS"
 )
 
@@ -28,6 +32,9 @@ var (
 	ErrInvalidTokenContent = errors.New("invalid token content")
 	ErrInvalidToken        = errors.New("token is invalid")
 	ErrExpiredToken        = errors.New("token has expired")
+	ErrMissingClaim        = errors.New("claim is missing")
+	ErrInvalidAudience     = errors.New("token audience is invalid")
+	ErrRevokedToken        = errors.New("token has been revoked")
 )
 
 // Credentials credentials to issue and verify JWT tokens.
@@ -38,14 +45,60 @@ type Credentials struct {
 
 // User user authenicatated in JWT.
 type User struct {
-	ID    string
-	Roles []string
+	ID          string
+	Roles       []string
+	Permissions []string
+	ClientID    string
+	SessionID   string
+	OriginID    string
+	CareUnitID  string
+	Extra       map[string]interface{}
+	TokenID     string
+	ExpiresAt   time.Time
+	ActorID     string
+	hierarchy   *RoleHierarchy
+}
+
+// AnonymousUser returns the user representing an unauthenticated caller.
+func AnonymousUser() User {
+	return User{
+		Roles: []string{AnonymousRole},
+	}
 }
 
 func (u User) String() string {
 	return fmt.Sprintf("User(id=%s, roles=%v)", u.ID, u.Roles)
 }
 
+// OnBehalfOf returns a user acting on behalf of a principal, recording the ID of the end user
+// that originally triggered the call as the actor. If the principal is itself acting on behalf
+// of another user, that user is kept as the actor.
+func (u User) OnBehalfOf(principal User) User {
+	actorID := principal.ActorID
+	if actorID == "" {
+		actorID = principal.ID
+	}
+
+	u.ActorID = actorID
+	return u
+}
+
+// ExtraClaim decodes a named extra claim into v, which should be a pointer to a value of the claim type.
+// Returns ErrMissingClaim if the user has no such claim.
+func (u User) ExtraClaim(name string, v interface{}) error {
+	value, ok := u.Extra[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrMissingClaim, name)
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
 // IsSystem checks if a user has the SYSTEM role.
 func (u User) IsSystem() bool {
 	return u.HasRole(SystemRole)
@@ -61,10 +114,11 @@ func (u User) IsAnonymous() bool {
 	return u.HasRole(AnonymousRole)
 }
 
-// HasRole checks if a users has a given role.
+// HasRole checks if a users has a given role, either directly or
+// inherited through the role hierarchy applied to the user.
 func (u User) HasRole(candidate string) bool {
 	for _, role := range u.Roles {
-		if role == candidate {
+		if u.hierarchy.Implies(role, candidate) {
 			return true
 		}
 	}
@@ -72,9 +126,57 @@ func (u User) HasRole(candidate string) bool {
 	return false
 }
 
-// Issuer interface for issuing auth tokens
+// EffectiveRoles returns the roles of the user including roles inherited through the role hierarchy.
+func (u User) EffectiveRoles() []string {
+	if u.hierarchy == nil {
+		return u.Roles
+	}
+
+	return u.hierarchy.Expand(u.Roles...)
+}
+
+// HasPermission checks if a user has been granted a given permission.
+func (u User) HasPermission(candidate string) bool {
+	for _, permission := range u.Permissions {
+		if permission == candidate {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasAllPermissions checks if a user has been granted all of a list of permissions.
+func (u User) HasAllPermissions(permissions ...string) bool {
+	return len(u.MissingPermissions(permissions...)) == 0
+}
+
+// HasAnyPermission checks if a user has been granted any of a list of permissions.
+func (u User) HasAnyPermission(permissions ...string) bool {
+	for _, permission := range permissions {
+		if u.HasPermission(permission) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MissingPermissions returns the permissions in a list that the user has not been granted.
+func (u User) MissingPermissions(permissions ...string) []string {
+	missing := make([]string, 0)
+	for _, permission := range permissions {
+		if !u.HasPermission(permission) {
+			missing = append(missing, permission)
+		}
+	}
+
+	return missing
+}
+
+// Issuer interface for issuing auth tokens, optionally restricted to one or more audiences.
 type Issuer interface {
-	Issue(user User, lifetime time.Duration) (string, error)
+	Issue(user User, lifetime time.Duration, audience ...string) (string, error)
 }
 
 // NewIssuer creates a new Issuer using the default implementation.
@@ -90,26 +192,79 @@ func NewIssuer(creds Credentials) Issuer {
 	}
 }
 
+// NewAsymmetricIssuer creates a new Issuer signing tokens with an RSA (RS256), ECDSA (ES256, ES384, ES512)
+// or Ed25519 (EdDSA) private key, such as one returned by ParsePrivateKey.
+func NewAsymmetricIssuer(issuer string, privateKey crypto.PrivateKey) (Issuer, error) {
+	if !isPrivateKey(privateKey) {
+		return nil, fmt.Errorf("%w: %T is not a private key", ErrUnsupportedKey, privateKey)
+	}
+
+	alg, err := signingAlgorithm(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: privateKey}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jose.Signer: %w", err)
+	}
+
+	return &jwtIssuer{
+		name:   issuer,
+		signer: signer,
+	}, nil
+}
+
 // Verifier interface for verifying tokens.
 type Verifier interface {
 	Verify(token string) (User, error)
 }
 
 // NewVerifier creates a new Verifier using the default implementation.
-func NewVerifier(creds Credentials, leeway time.Duration) Verifier {
+// If an expected audience is supplied, only tokens issued for any of them are accepted.
+func NewVerifier(creds Credentials, leeway time.Duration, audience ...string) Verifier {
 	return &jwtVerifier{
-		secret:         []byte(creds.Secret),
+		keys:           staticKeySource{key: Key{Key: []byte(creds.Secret)}},
 		expectedIssuer: creds.Issuer,
+		audience:       audience,
 		leeway:         leeway,
 	}
 }
 
+// NewAsymmetricVerifier creates a new Verifier checking token signatures with an RSA, ECDSA or Ed25519
+// public key, such as one returned by ParsePublicKey. Only tokens signed with the algorithm matching
+// the key type are accepted. If an expected audience is supplied, only tokens issued for any of them are accepted.
+func NewAsymmetricVerifier(issuer string, publicKey crypto.PublicKey, leeway time.Duration, audience ...string) (Verifier, error) {
+	if isPrivateKey(publicKey) {
+		return nil, fmt.Errorf("%w: expected public key, got %T", ErrInvalidKey, publicKey)
+	}
+
+	_, err := signingAlgorithm(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jwtVerifier{
+		keys:           staticKeySource{key: Key{Key: publicKey}},
+		expectedIssuer: issuer,
+		audience:       audience,
+		leeway:         leeway,
+	}, nil
+}
+
 type customClaims struct {
-	ClientID   string `json:"cid,omitempty"`
-	SessionID  string `json:"sid,omitempty"`
-	OriginID   string `json:"org,omitempty"`
-	CareUnitID string `json:"cu,omitempty"`
-	Roles      string `json:"role,omitempty"`
+	ClientID   string                 `json:"cid,omitempty"`
+	SessionID  string                 `json:"sid,omitempty"`
+	OriginID   string                 `json:"org,omitempty"`
+	CareUnitID string                 `json:"cu,omitempty"`
+	Roles      string                 `json:"role,omitempty"`
+	Extra      map[string]interface{} `json:"ext,omitempty"`
+	Actor      *actorClaim            `json:"act,omitempty"`
+}
+
+// actorClaim the party in an on-behalf-of token other than the subject.
+type actorClaim struct {
+	Subject string `json:"sub"`
 }
 
 type jwtIssuer struct {
@@ -117,7 +272,7 @@ type jwtIssuer struct {
 	signer jose.Signer
 }
 
-func (i *jwtIssuer) Issue(user User, lifetime time.Duration) (string, error) {
+func (i *jwtIssuer) Issue(user User, lifetime time.Duration, audience ...string) (string, error) {
 	err := i.verifyTokenContent(user)
 	if err != nil {
 		return "", err
@@ -125,15 +280,27 @@ func (i *jwtIssuer) Issue(user User, lifetime time.Duration) (string, error) {
 
 	now := time.Now()
 	claims := josejwt.Claims{
+		ID:        id.New(),
 		Subject:   user.ID,
 		Issuer:    i.name,
 		NotBefore: josejwt.NewNumericDate(now.Add(-1 * time.Minute)),
 		IssuedAt:  josejwt.NewNumericDate(now),
 		Expiry:    josejwt.NewNumericDate(now.Add(lifetime)),
 	}
+	if len(audience) > 0 {
+		claims.Audience = josejwt.Audience(audience)
+	}
 
 	custCl := customClaims{
-		Roles: strings.Join(user.Roles, roleDelimiter),
+		ClientID:   user.ClientID,
+		SessionID:  user.SessionID,
+		OriginID:   user.OriginID,
+		CareUnitID: user.CareUnitID,
+		Roles:      strings.Join(user.Roles, roleDelimiter),
+		Extra:      user.Extra,
+	}
+	if user.ActorID != "" {
+		custCl.Actor = &actorClaim{Subject: user.ActorID}
 	}
 	return josejwt.Signed(i.signer).Claims(claims).Claims(custCl).CompactSerialize()
 }
@@ -150,25 +317,38 @@ func (i *jwtIssuer) verifyTokenContent(user User) error {
 }
 
 type jwtVerifier struct {
-	secret         []byte
+	keys           KeySource
 	expectedIssuer string
+	audience       []string
 	leeway         time.Duration
 }
 
 func (v *jwtVerifier) Verify(rawToken string) (User, error) {
 	token, err := josejwt.ParseSigned(rawToken)
+	if err != nil || len(token.Headers) != 1 {
+		return User{}, ErrInvalidToken
+	}
+
+	header := token.Headers[0]
+	key, err := v.keys.VerificationKey(header.KeyID)
 	if err != nil {
 		return User{}, ErrInvalidToken
 	}
 
+	// Guards against algorithm confusion, e.g. a HS256 token signed with a public key.
+	alg, err := key.algorithm()
+	if err != nil || header.Algorithm != string(alg) {
+		return User{}, ErrInvalidToken
+	}
+
 	var claims josejwt.Claims
-	err = token.Claims(v.secret, &claims)
+	err = token.Claims(key.Key, &claims)
 	if err != nil {
 		return User{}, ErrInvalidToken
 	}
 
 	var customCl customClaims
-	err = token.Claims(v.secret, &customCl)
+	err = token.Claims(key.Key, &customCl)
 	if err != nil {
 		return User{}, ErrInvalidToken
 	}
@@ -196,7 +376,21 @@ func (v *jwtVerifier) validateClaims(claims josejwt.Claims) error {
 		return ErrInvalidToken
 	}
 
-	return nil
+	return v.checkAudience(claims)
+}
+
+func (v *jwtVerifier) checkAudience(claims josejwt.Claims) error {
+	if len(v.audience) == 0 {
+		return nil
+	}
+
+	for _, aud := range v.audience {
+		if claims.Audience.Contains(aud) {
+			return nil
+		}
+	}
+
+	return ErrInvalidAudience
 }
 
 func (v *jwtVerifier) checkTokenExpiry(claims josejwt.Claims) error {
@@ -216,8 +410,21 @@ func (v *jwtVerifier) checkTokenExpiry(claims josejwt.Claims) error {
 }
 
 func getTokenFromClaims(claims josejwt.Claims, customCl customClaims) User {
+	actorID := ""
+	if customCl.Actor != nil {
+		actorID = customCl.Actor.Subject
+	}
+
 	return User{
-		ID:    claims.Subject,
-		Roles: strings.Split(customCl.Roles, roleDelimiter),
+		ID:         claims.Subject,
+		Roles:      strings.Split(customCl.Roles, roleDelimiter),
+		ClientID:   customCl.ClientID,
+		SessionID:  customCl.SessionID,
+		OriginID:   customCl.OriginID,
+		CareUnitID: customCl.CareUnitID,
+		Extra:      customCl.Extra,
+		TokenID:    claims.ID,
+		ExpiresAt:  claims.Expiry.Time(),
+		ActorID:    actorID,
 	}
 }
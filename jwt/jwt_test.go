@@ -1,6 +1,7 @@
 package jwt_test
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -155,3 +156,163 @@ func assertJWTUser(t *testing.T, expected, found jwt.User) {
 	assert.Equal(expected.ID, found.ID)
 	assert.Equal(expected.Roles, found.Roles)
 }
+
+func TestJWTCustomClaims(t *testing.T) {
+	assert := assert.New(t)
+	creds := jwt.Credentials{
+		Issuer: "issuer-name",
+		Secret: "super-secret-token",
+	}
+	issuer := jwt.NewIssuer(creds)
+	verifier := jwt.NewVerifier(creds, time.Minute)
+
+	type tenant struct {
+		ID   string `json:"id"`
+		Tier int    `json:"tier"`
+	}
+
+	user := jwt.User{
+		ID:         "user-id",
+		Roles:      []string{jwt.UserRole, jwt.AdminRole},
+		ClientID:   "client-id",
+		SessionID:  "session-id",
+		OriginID:   "origin-id",
+		CareUnitID: "care-unit-id",
+		Extra: map[string]interface{}{
+			"tenant": tenant{ID: "tenant-id", Tier: 2},
+			"locale": "sv-SE",
+		},
+	}
+
+	token, err := issuer.Issue(user, time.Hour)
+	assert.NoError(err)
+
+	verified, err := verifier.Verify(token)
+	assert.NoError(err)
+	assert.Equal(user.ID, verified.ID)
+	assert.Equal(user.Roles, verified.Roles)
+	assert.Equal(user.ClientID, verified.ClientID)
+	assert.Equal(user.SessionID, verified.SessionID)
+	assert.Equal(user.OriginID, verified.OriginID)
+	assert.Equal(user.CareUnitID, verified.CareUnitID)
+
+	var t1 tenant
+	err = verified.ExtraClaim("tenant", &t1)
+	assert.NoError(err)
+	assert.Equal(tenant{ID: "tenant-id", Tier: 2}, t1)
+
+	var locale string
+	err = verified.ExtraClaim("locale", &locale)
+	assert.NoError(err)
+	assert.Equal("sv-SE", locale)
+
+	err = verified.ExtraClaim("missing", &locale)
+	assert.True(errors.Is(err, jwt.ErrMissingClaim))
+}
+
+func TestJWTAudience(t *testing.T) {
+	assert := assert.New(t)
+	creds := jwt.Credentials{
+		Issuer: "issuer-name",
+		Secret: "super-secret-token",
+	}
+	issuer := jwt.NewIssuer(creds)
+	user := jwt.User{
+		ID:    "user-id",
+		Roles: []string{jwt.UserRole},
+	}
+
+	tests := []struct {
+		name     string
+		audience []string
+		verifier jwt.Verifier
+		wantErr  error
+	}{
+		{
+			name:     "matching-audience",
+			audience: []string{"user-service"},
+			verifier: jwt.NewVerifier(creds, time.Minute, "user-service"),
+			wantErr:  nil,
+		},
+		{
+			name:     "one-of-many-audiences",
+			audience: []string{"order-service", "user-service"},
+			verifier: jwt.NewVerifier(creds, time.Minute, "user-service"),
+			wantErr:  nil,
+		},
+		{
+			name:     "one-of-many-expected-audiences",
+			audience: []string{"user-service"},
+			verifier: jwt.NewVerifier(creds, time.Minute, "order-service", "user-service"),
+			wantErr:  nil,
+		},
+		{
+			name:     "wrong-audience",
+			audience: []string{"order-service"},
+			verifier: jwt.NewVerifier(creds, time.Minute, "user-service"),
+			wantErr:  jwt.ErrInvalidAudience,
+		},
+		{
+			name:     "missing-audience",
+			audience: nil,
+			verifier: jwt.NewVerifier(creds, time.Minute, "user-service"),
+			wantErr:  jwt.ErrInvalidAudience,
+		},
+		{
+			name:     "no-audience-expected",
+			audience: []string{"order-service"},
+			verifier: jwt.NewVerifier(creds, time.Minute),
+			wantErr:  nil,
+		},
+	}
+
+	for _, tc := range tests {
+		token, err := issuer.Issue(user, time.Hour, tc.audience...)
+		assert.NoError(err, tc.name)
+
+		verified, err := tc.verifier.Verify(token)
+		if tc.wantErr != nil {
+			assert.True(errors.Is(err, tc.wantErr), "%s: expected %v got %v", tc.name, tc.wantErr, err)
+			continue
+		}
+
+		assert.NoError(err, tc.name)
+		assert.Equal(user.ID, verified.ID, tc.name)
+	}
+}
+
+func TestJWTOnBehalfOf(t *testing.T) {
+	assert := assert.New(t)
+	creds := jwt.Credentials{
+		Issuer: "issuer-name",
+		Secret: "super-secret-token",
+	}
+	issuer := jwt.NewIssuer(creds)
+	verifier := jwt.NewVerifier(creds, time.Minute)
+
+	endUser := jwt.User{
+		ID:    "end-user-id",
+		Roles: []string{jwt.UserRole},
+	}
+	service := jwt.User{
+		ID:    "order-service",
+		Roles: []string{jwt.SystemRole},
+	}
+
+	token, err := issuer.Issue(service.OnBehalfOf(endUser), time.Hour)
+	assert.NoError(err)
+	verified, err := verifier.Verify(token)
+	assert.NoError(err)
+	assert.Equal("order-service", verified.ID)
+	assert.Equal([]string{jwt.SystemRole}, verified.Roles)
+	assert.Equal("end-user-id", verified.ActorID)
+
+	next := jwt.User{ID: "payment-service", Roles: []string{jwt.SystemRole}}.OnBehalfOf(verified)
+	assert.Equal("end-user-id", next.ActorID)
+
+	token, err = issuer.Issue(endUser, time.Hour)
+	assert.NoError(err)
+	verified, err = verifier.Verify(token)
+	assert.NoError(err)
+	assert.Empty(verified.ActorID)
+}
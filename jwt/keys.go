@@ -0,0 +1,152 @@
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+// Key errors.
+var (
+	ErrUnsupportedKey = errors.New("unsupported key type")
+	ErrInvalidKey     = errors.New("invalid key")
+)
+
+// ParsePrivateKey parses a PEM encoded (PKCS #8, PKCS #1 or SEC 1) or JWK encoded
+// RSA, ECDSA or Ed25519 private key.
+func ParsePrivateKey(data []byte) (crypto.PrivateKey, error) {
+	if isJWK(data) {
+		key, err := parseJWK(data)
+		if err != nil {
+			return nil, err
+		}
+		if key.IsPublic() {
+			return nil, fmt.Errorf("%w: expected private key, got public JWK", ErrInvalidKey)
+		}
+		return key.Key, nil
+	}
+
+	block, err := decodePEM(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: unexpected PEM block type %s", ErrInvalidKey, block.Type)
+	}
+}
+
+// ParsePublicKey parses a PEM encoded (PKIX, PKCS #1 or X.509 certificate) or JWK encoded
+// RSA, ECDSA or Ed25519 public key.
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	if isJWK(data) {
+		key, err := parseJWK(data)
+		if err != nil {
+			return nil, err
+		}
+		if !key.IsPublic() {
+			return nil, fmt.Errorf("%w: expected public key, got private JWK", ErrInvalidKey)
+		}
+		return key.Key, nil
+	}
+
+	block, err := decodePEM(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	default:
+		return nil, fmt.Errorf("%w: unexpected PEM block type %s", ErrInvalidKey, block.Type)
+	}
+}
+
+// signingAlgorithm determines the signature algorithm to use with a private or public key.
+func signingAlgorithm(key interface{}) (jose.SignatureAlgorithm, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		return jose.RS256, nil
+	case *ecdsa.PrivateKey:
+		return ecdsaAlgorithm(k.Curve)
+	case *ecdsa.PublicKey:
+		return ecdsaAlgorithm(k.Curve)
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return jose.EdDSA, nil
+	default:
+		return "", fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+	}
+}
+
+func ecdsaAlgorithm(curve elliptic.Curve) (jose.SignatureAlgorithm, error) {
+	switch curve {
+	case elliptic.P256():
+		return jose.ES256, nil
+	case elliptic.P384():
+		return jose.ES384, nil
+	case elliptic.P521():
+		return jose.ES512, nil
+	default:
+		return "", fmt.Errorf("%w: unsupported elliptic curve %s", ErrUnsupportedKey, curve.Params().Name)
+	}
+}
+
+func isPrivateKey(key interface{}) bool {
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return true
+	default:
+		return false
+	}
+}
+
+func isJWK(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
+
+func parseJWK(data []byte) (*jose.JSONWebKey, error) {
+	var key jose.JSONWebKey
+	err := key.UnmarshalJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	if !key.Valid() {
+		return nil, ErrInvalidKey
+	}
+
+	return &key, nil
+}
+
+func decodePEM(data []byte) (*pem.Block, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM data found", ErrInvalidKey)
+	}
+
+	return block, nil
+}
@@ -0,0 +1,135 @@
+package jwt_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/httputil/jwt"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/square/go-jose.v2"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+)
+
+func TestAsymmetricIssueAndVerify(t *testing.T) {
+	assert := assert.New(t)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(err)
+
+	user := jwt.User{
+		ID:    "user-id",
+		Roles: []string{jwt.UserRole},
+	}
+
+	for _, privateKey := range []crypto.Signer{rsaKey, ecKey, edKey} {
+		pemPrivate := encodePrivateKeyPEM(t, privateKey)
+		parsedPrivate, err := jwt.ParsePrivateKey(pemPrivate)
+		assert.NoError(err)
+
+		pemPublic := encodePublicKeyPEM(t, privateKey.Public())
+		parsedPublic, err := jwt.ParsePublicKey(pemPublic)
+		assert.NoError(err)
+
+		jwk, err := jose.JSONWebKey{Key: privateKey.Public()}.MarshalJSON()
+		assert.NoError(err)
+		jwkPublic, err := jwt.ParsePublicKey(jwk)
+		assert.NoError(err)
+
+		issuer, err := jwt.NewAsymmetricIssuer("issuer-name", parsedPrivate)
+		assert.NoError(err)
+
+		for _, publicKey := range []crypto.PublicKey{parsedPublic, jwkPublic} {
+			verifier, err := jwt.NewAsymmetricVerifier("issuer-name", publicKey, time.Minute)
+			assert.NoError(err)
+
+			token, err := issuer.Issue(user, time.Hour)
+			assert.NoError(err)
+
+			verified, err := verifier.Verify(token)
+			assert.NoError(err)
+			assertJWTUser(t, user, verified)
+		}
+
+		_, err = jwt.NewAsymmetricVerifier("issuer-name", privateKey, time.Minute)
+		assert.Error(err)
+		_, err = jwt.NewAsymmetricIssuer("issuer-name", privateKey.Public())
+		assert.Error(err)
+		_, err = jwt.ParsePrivateKey(pemPublic)
+		assert.Error(err)
+		_, err = jwt.ParsePublicKey(jwkOf(t, privateKey))
+		assert.Error(err)
+	}
+
+	rsaIssuer, err := jwt.NewAsymmetricIssuer("issuer-name", rsaKey)
+	assert.NoError(err)
+	ecVerifier, err := jwt.NewAsymmetricVerifier("issuer-name", ecKey.Public(), time.Minute)
+	assert.NoError(err)
+	token, err := rsaIssuer.Issue(user, time.Hour)
+	assert.NoError(err)
+	_, err = ecVerifier.Verify(token)
+	assert.Equal(jwt.ErrInvalidToken, err)
+}
+
+func TestAsymmetricVerify_AlgorithmConfusion(t *testing.T) {
+	assert := assert.New(t)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(err)
+	pemPublic := encodePublicKeyPEM(t, rsaKey.Public())
+
+	verifier, err := jwt.NewAsymmetricVerifier("issuer-name", rsaKey.Public(), time.Minute)
+	assert.NoError(err)
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: pemPublic}, nil)
+	assert.NoError(err)
+	now := time.Now()
+	token, err := josejwt.Signed(signer).Claims(josejwt.Claims{
+		Subject:   "attacker",
+		Issuer:    "issuer-name",
+		NotBefore: josejwt.NewNumericDate(now.Add(-time.Minute)),
+		IssuedAt:  josejwt.NewNumericDate(now),
+		Expiry:    josejwt.NewNumericDate(now.Add(time.Hour)),
+	}).Claims(map[string]interface{}{"role": jwt.AdminRole}).CompactSerialize()
+	assert.NoError(err)
+
+	_, err = verifier.Verify(token)
+	assert.Equal(jwt.ErrInvalidToken, err)
+
+	hmacVerifier := jwt.NewVerifier(jwt.Credentials{Issuer: "issuer-name", Secret: "secret"}, time.Minute)
+	rsaIssuer, err := jwt.NewAsymmetricIssuer("issuer-name", rsaKey)
+	assert.NoError(err)
+	token, err = rsaIssuer.Issue(jwt.User{ID: "user-id", Roles: []string{jwt.UserRole}}, time.Hour)
+	assert.NoError(err)
+	_, err = hmacVerifier.Verify(token)
+	assert.Equal(jwt.ErrInvalidToken, err)
+}
+
+func encodePrivateKeyPEM(t *testing.T, key crypto.PrivateKey) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func encodePublicKeyPEM(t *testing.T, key crypto.PublicKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func jwkOf(t *testing.T, key interface{}) []byte {
+	data, err := jose.JSONWebKey{Key: key}.MarshalJSON()
+	assert.NoError(t, err)
+	return data
+}
@@ -0,0 +1,274 @@
+package jwt
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+// Key set errors.
+var (
+	ErrUnknownKeyID = errors.New("unknown key id")
+	ErrNoSigningKey = errors.New("no signing key available")
+)
+
+// Key signing or verification key identified by a key ID. The key is either a HMAC
+// secret as a []byte, or an RSA, ECDSA or Ed25519 private or public key.
+type Key struct {
+	ID  string
+	Key interface{}
+}
+
+// algorithm determines the signature algorithm used with the key.
+func (k Key) algorithm() (jose.SignatureAlgorithm, error) {
+	if k.isSymmetric() {
+		return jose.HS256, nil
+	}
+
+	return signingAlgorithm(k.Key)
+}
+
+func (k Key) isSymmetric() bool {
+	_, ok := k.Key.([]byte)
+	return ok
+}
+
+// KeySource resolves the key to verify a token with from the key ID in the token header.
+type KeySource interface {
+	VerificationKey(kid string) (Key, error)
+}
+
+// KeySet holds the current signing key and previous keys that are still accepted for verification,
+// allowing keys to be rotated without invalidating outstanding tokens.
+type KeySet struct {
+	mu         sync.RWMutex
+	currentID  string
+	signing    map[string]Key
+	verifying  map[string]Key
+	insertions []string
+}
+
+// NewKeySet creates a KeySet where the supplied key is used for signing.
+func NewKeySet(kid string, key interface{}) (*KeySet, error) {
+	ks := &KeySet{
+		signing:   make(map[string]Key),
+		verifying: make(map[string]Key),
+	}
+
+	err := ks.Rotate(kid, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return ks, nil
+}
+
+// Rotate adds a key and makes it the current signing key.
+// The previous signing key is kept for verification until removed.
+func (ks *KeySet) Rotate(kid string, key interface{}) error {
+	if !isPrivateKey(key) {
+		if _, ok := key.([]byte); !ok {
+			return fmt.Errorf("%w: %T cannot be used for signing", ErrUnsupportedKey, key)
+		}
+	}
+
+	err := ks.Add(kid, key)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.currentID = kid
+	return nil
+}
+
+// Add adds a key accepted for verification. Private keys may be made the signing key by a later Rotate.
+func (ks *KeySet) Add(kid string, key interface{}) error {
+	if kid == "" {
+		return fmt.Errorf("%w: key id must not be empty", ErrInvalidKey)
+	}
+
+	signingKey := Key{ID: kid, Key: key}
+	_, err := signingKey.algorithm()
+	if err != nil {
+		return err
+	}
+
+	verificationKey := signingKey
+	if isPrivateKey(key) {
+		verificationKey.Key = key.(crypto.Signer).Public()
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if _, ok := ks.verifying[kid]; !ok {
+		ks.insertions = append(ks.insertions, kid)
+	}
+	if isPrivateKey(key) || verificationKey.isSymmetric() {
+		ks.signing[kid] = signingKey
+	}
+	ks.verifying[kid] = verificationKey
+	return nil
+}
+
+// Remove removes a key, tokens signed with it will no longer be accepted.
+// The current signing key cannot be removed.
+func (ks *KeySet) Remove(kid string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if kid == ks.currentID {
+		return fmt.Errorf("cannot remove current signing key %s", kid)
+	}
+
+	delete(ks.signing, kid)
+	delete(ks.verifying, kid)
+	for i, id := range ks.insertions {
+		if id == kid {
+			ks.insertions = append(ks.insertions[:i], ks.insertions[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+// SigningKey returns the current signing key.
+func (ks *KeySet) SigningKey() (Key, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	key, ok := ks.signing[ks.currentID]
+	if !ok {
+		return Key{}, ErrNoSigningKey
+	}
+
+	return key, nil
+}
+
+// VerificationKey returns the key with the given ID. Tokens without a
+// key ID are verified with the current signing key.
+func (ks *KeySet) VerificationKey(kid string) (Key, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	if kid == "" {
+		kid = ks.currentID
+	}
+
+	key, ok := ks.verifying[kid]
+	if !ok {
+		return Key{}, fmt.Errorf("%w: %s", ErrUnknownKeyID, kid)
+	}
+
+	return key, nil
+}
+
+// PublicJWKS returns the public keys of the set as a JSON Web Key Set. HMAC secrets are never included.
+func (ks *KeySet) PublicJWKS() jose.JSONWebKeySet {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	jwks := jose.JSONWebKeySet{
+		Keys: make([]jose.JSONWebKey, 0, len(ks.verifying)),
+	}
+	for _, kid := range ks.insertions {
+		key := ks.verifying[kid]
+		if key.isSymmetric() {
+			continue
+		}
+
+		alg, _ := key.algorithm()
+		jwks.Keys = append(jwks.Keys, jose.JSONWebKey{
+			Key:       key.Key,
+			KeyID:     key.ID,
+			Algorithm: string(alg),
+			Use:       "sig",
+		})
+	}
+
+	return jwks
+}
+
+// NewKeySetIssuer creates a new Issuer signing tokens with the current key of a KeySet
+// and setting its ID as the kid header.
+func NewKeySetIssuer(issuer string, keys *KeySet) Issuer {
+	return &keySetIssuer{
+		name:    issuer,
+		keys:    keys,
+		signers: make(map[string]jose.Signer),
+	}
+}
+
+// NewKeySetVerifier creates a new Verifier selecting the verification key by the kid header of a token.
+// If an expected audience is supplied, only tokens issued for any of them are accepted.
+func NewKeySetVerifier(issuer string, keys KeySource, leeway time.Duration, audience ...string) Verifier {
+	return &jwtVerifier{
+		keys:           keys,
+		expectedIssuer: issuer,
+		audience:       audience,
+		leeway:         leeway,
+	}
+}
+
+type keySetIssuer struct {
+	name    string
+	keys    *KeySet
+	mu      sync.Mutex
+	signers map[string]jose.Signer
+}
+
+func (i *keySetIssuer) Issue(user User, lifetime time.Duration, audience ...string) (string, error) {
+	signer, err := i.getSigner()
+	if err != nil {
+		return "", err
+	}
+
+	issuer := &jwtIssuer{
+		name:   i.name,
+		signer: signer,
+	}
+	return issuer.Issue(user, lifetime, audience...)
+}
+
+func (i *keySetIssuer) getSigner() (jose.Signer, error) {
+	key, err := i.keys.SigningKey()
+	if err != nil {
+		return nil, err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if signer, ok := i.signers[key.ID]; ok {
+		return signer, nil
+	}
+
+	alg, err := key.algorithm()
+	if err != nil {
+		return nil, err
+	}
+
+	signingKey := jose.SigningKey{Algorithm: alg, Key: key.Key}
+	opts := (&jose.SignerOptions{}).WithHeader("kid", key.ID)
+	signer, err := jose.NewSigner(signingKey, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jose.Signer: %w", err)
+	}
+
+	i.signers[key.ID] = signer
+	return signer, nil
+}
+
+// staticKeySource key source always returning the same key, regardless of key ID.
+type staticKeySource struct {
+	key Key
+}
+
+func (s staticKeySource) VerificationKey(kid string) (Key, error) {
+	return s.key, nil
+}
@@ -0,0 +1,101 @@
+package jwt_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/httputil/jwt"
+	"github.com/stretchr/testify/assert"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+)
+
+func TestKeySetRotation(t *testing.T) {
+	assert := assert.New(t)
+	user := jwt.User{
+		ID:    "user-id",
+		Roles: []string{jwt.UserRole},
+	}
+
+	keys, err := jwt.NewKeySet("secret-1", []byte("first-secret"))
+	assert.NoError(err)
+	issuer := jwt.NewKeySetIssuer("issuer-name", keys)
+	verifier := jwt.NewKeySetVerifier("issuer-name", keys, time.Minute)
+
+	oldToken, err := issuer.Issue(user, time.Hour)
+	assert.NoError(err)
+	assert.Equal("secret-1", keyID(t, oldToken))
+
+	legacyToken, err := jwt.NewIssuer(jwt.Credentials{Issuer: "issuer-name", Secret: "first-secret"}).Issue(user, time.Hour)
+	assert.NoError(err)
+	_, err = verifier.Verify(legacyToken)
+	assert.NoError(err)
+
+	err = keys.Rotate("secret-2", []byte("second-secret"))
+	assert.NoError(err)
+
+	newToken, err := issuer.Issue(user, time.Hour)
+	assert.NoError(err)
+	assert.Equal("secret-2", keyID(t, newToken))
+
+	for _, token := range []string{oldToken, newToken} {
+		verified, err := verifier.Verify(token)
+		assert.NoError(err)
+		assertJWTUser(t, user, verified)
+	}
+
+	_, err = verifier.Verify(legacyToken)
+	assert.Equal(jwt.ErrInvalidToken, err)
+
+	err = keys.Remove("secret-2")
+	assert.Error(err)
+	err = keys.Remove("secret-1")
+	assert.NoError(err)
+
+	_, err = verifier.Verify(oldToken)
+	assert.Equal(jwt.ErrInvalidToken, err)
+	_, err = verifier.Verify(newToken)
+	assert.NoError(err)
+
+	_, err = keys.VerificationKey("secret-1")
+	assert.True(errors.Is(err, jwt.ErrUnknownKeyID))
+}
+
+func TestKeySetPublicJWKS(t *testing.T) {
+	assert := assert.New(t)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(err)
+
+	keys, err := jwt.NewKeySet("ec-1", ecKey)
+	assert.NoError(err)
+	err = keys.Add("hmac-1", []byte("secret"))
+	assert.NoError(err)
+	err = keys.Add("ec-other", otherKey.Public())
+	assert.NoError(err)
+	err = keys.Rotate("ec-public", otherKey.Public())
+	assert.Error(err)
+	err = keys.Add("", []byte("secret"))
+	assert.Error(err)
+
+	jwks := keys.PublicJWKS()
+	assert.Len(jwks.Keys, 2)
+	assert.Equal("ec-1", jwks.Keys[0].KeyID)
+	assert.Equal("ES256", jwks.Keys[0].Algorithm)
+	assert.Equal("sig", jwks.Keys[0].Use)
+	assert.True(jwks.Keys[0].IsPublic())
+	assert.Equal("ec-other", jwks.Keys[1].KeyID)
+}
+
+func keyID(t *testing.T, rawToken string) string {
+	token, err := josejwt.ParseSigned(rawToken)
+	assert.NoError(t, err)
+	assert.False(t, strings.Contains(rawToken, " "))
+	return token.Headers[0].KeyID
+}
@@ -0,0 +1,62 @@
+package jwt
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// RolePermissions maps roles to the permissions granted to them.
+type RolePermissions map[string][]string
+
+// LoadRolePermissions reads a role to permission mapping from a JSON or YAML file.
+func LoadRolePermissions(path string) (RolePermissions, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read role permissions from %s: %w", path, err)
+	}
+
+	var permissions RolePermissions
+	switch filepath.Ext(path) {
+	case ".json":
+		err = json.Unmarshal(data, &permissions)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &permissions)
+	default:
+		return nil, fmt.Errorf("unsupported role permissions file format: %s", path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse role permissions from %s: %w", path, err)
+	}
+
+	return permissions, nil
+}
+
+// PermissionsOf returns the sorted and deduplicated permissions granted to a list of roles.
+func (rp RolePermissions) PermissionsOf(roles ...string) []string {
+	unique := make(map[string]bool)
+	for _, role := range roles {
+		for _, permission := range rp[role] {
+			unique[permission] = true
+		}
+	}
+
+	permissions := make([]string, 0, len(unique))
+	for permission := range unique {
+		permissions = append(permissions, permission)
+	}
+
+	sort.Strings(permissions)
+	return permissions
+}
+
+// Grant returns a copy of the user with the permissions of its effective roles.
+func (rp RolePermissions) Grant(user User) User {
+	user.Permissions = rp.PermissionsOf(user.EffectiveRoles()...)
+	return user
+}
@@ -0,0 +1,46 @@
+package jwt_test
+
+import (
+	"testing"
+
+	"github.com/CzarSimon/httputil/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRolePermissions(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, path := range []string{"./resources/test_permissions.json", "./resources/test_permissions.yaml"} {
+		permissions, err := jwt.LoadRolePermissions(path)
+		assert.NoError(err, path)
+		assert.Equal([]string{"orders:read"}, permissions["USER"], path)
+		assert.Equal([]string{"orders:read", "orders:write", "users:read"}, permissions.PermissionsOf("USER", "ADMIN"), path)
+	}
+
+	_, err := jwt.LoadRolePermissions("./resources/missing.json")
+	assert.Error(err)
+
+	_, err = jwt.LoadRolePermissions("./resources/test_permissions.txt")
+	assert.Error(err)
+}
+
+func TestUserPermissions(t *testing.T) {
+	assert := assert.New(t)
+	permissions := jwt.RolePermissions{
+		"USER":  {"orders:read"},
+		"ADMIN": {"orders:write"},
+	}
+
+	user := permissions.Grant(jwt.User{
+		ID:    "user-id",
+		Roles: []string{"USER"},
+	})
+
+	assert.True(user.HasPermission("orders:read"))
+	assert.False(user.HasPermission("orders:write"))
+	assert.True(user.HasAllPermissions("orders:read"))
+	assert.False(user.HasAllPermissions("orders:read", "orders:write"))
+	assert.True(user.HasAnyPermission("orders:read", "orders:write"))
+	assert.False(user.HasAnyPermission("orders:write"))
+	assert.Equal([]string{"orders:write"}, user.MissingPermissions("orders:read", "orders:write"))
+}
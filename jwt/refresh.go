@@ -0,0 +1,343 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/CzarSimon/httputil/id"
+	"go.uber.org/zap"
+)
+
+const refreshTokenBytes = 32
+
+// Refresh token errors.
+var (
+	ErrInvalidRefreshToken  = errors.New("refresh token is invalid")
+	ErrRefreshTokenReused   = errors.New("refresh token has already been used")
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+)
+
+// RefreshToken stored state of an opaque refresh token. Tokens are stored by the SHA-256 hash
+// of their value, never the value itself. Every rotation issues a new token in the same family,
+// so that the whole chain can be revoked if a used token is presented again.
+// The claims of the user are kept so that refreshed access tokens carry the same claims.
+type RefreshToken struct {
+	ID         string
+	FamilyID   string
+	SessionID  string
+	UserID     string
+	Roles      []string
+	ClientID   string
+	OriginID   string
+	CareUnitID string
+	ActorID    string
+	Extra      map[string]interface{}
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	Used       bool
+	Revoked    bool
+}
+
+// User returns the user the refresh token was issued to.
+func (t RefreshToken) User() User {
+	return User{
+		ID:         t.UserID,
+		Roles:      t.Roles,
+		ClientID:   t.ClientID,
+		SessionID:  t.SessionID,
+		OriginID:   t.OriginID,
+		CareUnitID: t.CareUnitID,
+		ActorID:    t.ActorID,
+		Extra:      t.Extra,
+	}
+}
+
+// RefreshTokenStore persists refresh tokens.
+type RefreshTokenStore interface {
+	// Save stores a new refresh token.
+	Save(ctx context.Context, token RefreshToken) error
+	// Find returns the refresh token with the given ID or ErrRefreshTokenNotFound.
+	Find(ctx context.Context, id string) (RefreshToken, error)
+	// Rotate marks the token with the given ID as used and saves its successor in one transaction.
+	// Returns false without saving the successor if the token already was used or has been revoked.
+	Rotate(ctx context.Context, id string, next RefreshToken) (bool, error)
+	// RevokeFamily revokes all tokens in a token family.
+	RevokeFamily(ctx context.Context, familyID string) error
+	// RevokeSession revokes all tokens issued for a session.
+	RevokeSession(ctx context.Context, sessionID string) error
+}
+
+// TokenPair access token and the refresh token used to renew it.
+type TokenPair struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	TokenType    string `json:"tokenType"`
+	ExpiresIn    int    `json:"expiresIn"`
+}
+
+// RefreshTokens issues access tokens together with refresh tokens, rotating the
+// refresh token on every use and revoking the token family if a used token is reused.
+type RefreshTokens struct {
+	issuer          Issuer
+	store           RefreshTokenStore
+	accessLifetime  time.Duration
+	refreshLifetime time.Duration
+}
+
+// NewRefreshTokens creates a new RefreshTokens using an issuer for access tokens and a store for refresh tokens.
+func NewRefreshTokens(issuer Issuer, store RefreshTokenStore, accessLifetime, refreshLifetime time.Duration) *RefreshTokens {
+	return &RefreshTokens{
+		issuer:          issuer,
+		store:           store,
+		accessLifetime:  accessLifetime,
+		refreshLifetime: refreshLifetime,
+	}
+}
+
+// Issue issues a token pair starting a new token family. The refresh token is bound to the session
+// ID of the user, a new session ID is created if the user has none.
+func (r *RefreshTokens) Issue(ctx context.Context, user User) (TokenPair, error) {
+	if user.SessionID == "" {
+		user.SessionID = id.New()
+	}
+
+	pair, token, err := r.newTokenPair(user, id.New())
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	err = r.store.Save(ctx, token)
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("failed to save refresh token: %w", err)
+	}
+
+	return pair, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented refresh token can only be used once,
+// if it is presented again the whole token family is revoked and ErrRefreshTokenReused is returned.
+func (r *RefreshTokens) Refresh(ctx context.Context, refreshToken string) (TokenPair, error) {
+	token, err := r.find(ctx, refreshToken)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	if token.Used {
+		return TokenPair{}, r.handleReuse(ctx, token)
+	}
+	if token.Revoked {
+		return TokenPair{}, fmt.Errorf("%w: token has been revoked", ErrInvalidRefreshToken)
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return TokenPair{}, fmt.Errorf("%w: %v", ErrInvalidRefreshToken, ErrExpiredToken)
+	}
+
+	pair, next, err := r.newTokenPair(token.User(), token.FamilyID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	ok, err := r.store.Rotate(ctx, token.ID, next)
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	if !ok {
+		return TokenPair{}, r.handleRotationConflict(ctx, token)
+	}
+
+	return pair, nil
+}
+
+// handleRotationConflict handles a token that was used or revoked after it was found but before it was rotated.
+func (r *RefreshTokens) handleRotationConflict(ctx context.Context, token RefreshToken) error {
+	current, err := r.store.Find(ctx, token.ID)
+	if err != nil {
+		return fmt.Errorf("failed to find refresh token: %w", err)
+	}
+	if current.Revoked && !current.Used {
+		return fmt.Errorf("%w: token has been revoked", ErrInvalidRefreshToken)
+	}
+
+	return r.handleReuse(ctx, token)
+}
+
+// Revoke revokes the token family a refresh token belongs to.
+func (r *RefreshTokens) Revoke(ctx context.Context, refreshToken string) error {
+	token, err := r.find(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+
+	return r.store.RevokeFamily(ctx, token.FamilyID)
+}
+
+// RevokeSession revokes all refresh tokens issued for a session.
+func (r *RefreshTokens) RevokeSession(ctx context.Context, sessionID string) error {
+	return r.store.RevokeSession(ctx, sessionID)
+}
+
+// newTokenPair issues an access token and creates a refresh token in a token family, without storing it.
+func (r *RefreshTokens) newTokenPair(user User, familyID string) (TokenPair, RefreshToken, error) {
+	accessToken, err := r.issuer.Issue(user, r.accessLifetime)
+	if err != nil {
+		return TokenPair{}, RefreshToken{}, err
+	}
+
+	refreshToken, err := newRefreshTokenValue()
+	if err != nil {
+		return TokenPair{}, RefreshToken{}, err
+	}
+
+	now := time.Now()
+	token := RefreshToken{
+		ID:         hashRefreshToken(refreshToken),
+		FamilyID:   familyID,
+		SessionID:  user.SessionID,
+		UserID:     user.ID,
+		Roles:      user.Roles,
+		ClientID:   user.ClientID,
+		OriginID:   user.OriginID,
+		CareUnitID: user.CareUnitID,
+		ActorID:    user.ActorID,
+		Extra:      user.Extra,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(r.refreshLifetime),
+	}
+
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(r.accessLifetime.Seconds()),
+	}, token, nil
+}
+
+func (r *RefreshTokens) find(ctx context.Context, refreshToken string) (RefreshToken, error) {
+	token, err := r.store.Find(ctx, hashRefreshToken(refreshToken))
+	if errors.Is(err, ErrRefreshTokenNotFound) {
+		return RefreshToken{}, fmt.Errorf("%w: %v", ErrInvalidRefreshToken, err)
+	}
+	if err != nil {
+		return RefreshToken{}, fmt.Errorf("failed to find refresh token: %w", err)
+	}
+
+	return token, nil
+}
+
+func (r *RefreshTokens) handleReuse(ctx context.Context, token RefreshToken) error {
+	log.Warn("Refresh token reuse detected, revoking token family",
+		zap.String("familyId", token.FamilyID),
+		zap.String("sessionId", token.SessionID),
+		zap.String("userId", token.UserID))
+
+	err := r.store.RevokeFamily(ctx, token.FamilyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+
+	return ErrRefreshTokenReused
+}
+
+func newRefreshTokenValue() (string, error) {
+	b := make([]byte, refreshTokenBytes)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashRefreshToken(refreshToken string) string {
+	hash := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(hash[:])
+}
+
+// MemoryRefreshTokenStore in memory RefreshTokenStore, suitable for tests and single instance services.
+type MemoryRefreshTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]RefreshToken
+}
+
+// NewMemoryRefreshTokenStore creates a new, empty MemoryRefreshTokenStore.
+func NewMemoryRefreshTokenStore() *MemoryRefreshTokenStore {
+	return &MemoryRefreshTokenStore{
+		tokens: make(map[string]RefreshToken),
+	}
+}
+
+// Save stores a new refresh token.
+func (s *MemoryRefreshTokenStore) Save(ctx context.Context, token RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[token.ID] = token
+	return nil
+}
+
+// Find returns the refresh token with the given ID.
+func (s *MemoryRefreshTokenStore) Find(ctx context.Context, id string) (RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[id]
+	if !ok {
+		return RefreshToken{}, ErrRefreshTokenNotFound
+	}
+
+	return token, nil
+}
+
+// Rotate marks a token as used and saves its successor, returning false if the token already was used or has been revoked.
+func (s *MemoryRefreshTokenStore) Rotate(ctx context.Context, id string, next RefreshToken) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[id]
+	if !ok {
+		return false, ErrRefreshTokenNotFound
+	}
+	if token.Used || token.Revoked {
+		return false, nil
+	}
+
+	token.Used = true
+	s.tokens[id] = token
+	s.tokens[next.ID] = next
+	return true, nil
+}
+
+// RevokeFamily revokes all tokens in a token family.
+func (s *MemoryRefreshTokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	return s.revokeWhere(func(token RefreshToken) bool {
+		return token.FamilyID == familyID
+	})
+}
+
+// RevokeSession revokes all tokens issued for a session.
+func (s *MemoryRefreshTokenStore) RevokeSession(ctx context.Context, sessionID string) error {
+	return s.revokeWhere(func(token RefreshToken) bool {
+		return token.SessionID == sessionID
+	})
+}
+
+func (s *MemoryRefreshTokenStore) revokeWhere(match func(RefreshToken) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, token := range s.tokens {
+		if match(token) {
+			token.Revoked = true
+			s.tokens[id] = token
+		}
+	}
+
+	return nil
+}
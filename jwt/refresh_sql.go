@@ -0,0 +1,188 @@
+package jwt
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/CzarSimon/httputil/dbutil"
+)
+
+// SQLRefreshTokenStore RefreshTokenStore backed by a SQL database connected through dbutil.
+// Expects a table on the following form to exist:
+//
+//	CREATE TABLE `refresh_token` (
+//	  `id` VARCHAR(64) PRIMARY KEY,
+//	  `family_id` VARCHAR(50) NOT NULL,
+//	  `session_id` VARCHAR(50) NOT NULL,
+//	  `user_id` VARCHAR(50) NOT NULL,
+//	  `roles` VARCHAR(255) NOT NULL,
+//	  `client_id` VARCHAR(50) NOT NULL,
+//	  `origin_id` VARCHAR(50) NOT NULL DEFAULT '',
+//	  `care_unit_id` VARCHAR(50) NOT NULL DEFAULT '',
+//	  `actor_id` VARCHAR(50) NOT NULL DEFAULT '',
+//	  `extra` TEXT,
+//	  `created_at` DATETIME NOT NULL,
+//	  `expires_at` DATETIME NOT NULL,
+//	  `used` BOOLEAN NOT NULL DEFAULT FALSE,
+//	  `revoked` BOOLEAN NOT NULL DEFAULT FALSE
+//	);
+type SQLRefreshTokenStore struct {
+	db *sql.DB
+}
+
+// NewSQLRefreshTokenStore creates a new SQLRefreshTokenStore.
+func NewSQLRefreshTokenStore(db *sql.DB) *SQLRefreshTokenStore {
+	return &SQLRefreshTokenStore{
+		db: db,
+	}
+}
+
+const saveRefreshTokenQuery = `
+	INSERT INTO refresh_token(
+		id, family_id, session_id, user_id, roles, client_id, origin_id, care_unit_id, actor_id, extra, created_at, expires_at, used, revoked
+	)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// Save stores a new refresh token.
+func (s *SQLRefreshTokenStore) Save(ctx context.Context, token RefreshToken) error {
+	return saveRefreshToken(ctx, s.db, token)
+}
+
+// execer the part of *sql.DB and *sql.Tx used to save refresh tokens.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func saveRefreshToken(ctx context.Context, db execer, token RefreshToken) error {
+	var extra sql.NullString
+	if len(token.Extra) > 0 {
+		data, err := json.Marshal(token.Extra)
+		if err != nil {
+			return fmt.Errorf("failed to encode extra claims: %w", err)
+		}
+		extra = sql.NullString{String: string(data), Valid: true}
+	}
+
+	_, err := db.ExecContext(
+		ctx,
+		saveRefreshTokenQuery,
+		token.ID,
+		token.FamilyID,
+		token.SessionID,
+		token.UserID,
+		strings.Join(token.Roles, roleDelimiter),
+		token.ClientID,
+		token.OriginID,
+		token.CareUnitID,
+		token.ActorID,
+		extra,
+		token.CreatedAt.UTC(),
+		token.ExpiresAt.UTC(),
+		token.Used,
+		token.Revoked,
+	)
+
+	return err
+}
+
+const findRefreshTokenQuery = `
+	SELECT id, family_id, session_id, user_id, roles, client_id, origin_id, care_unit_id, actor_id, extra, created_at, expires_at, used, revoked
+	FROM refresh_token
+	WHERE id = ?`
+
+// Find returns the refresh token with the given ID.
+func (s *SQLRefreshTokenStore) Find(ctx context.Context, id string) (RefreshToken, error) {
+	var token RefreshToken
+	var roles string
+	var extra sql.NullString
+	err := s.db.QueryRowContext(ctx, findRefreshTokenQuery, id).Scan(
+		&token.ID,
+		&token.FamilyID,
+		&token.SessionID,
+		&token.UserID,
+		&roles,
+		&token.ClientID,
+		&token.OriginID,
+		&token.CareUnitID,
+		&token.ActorID,
+		&extra,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&token.Used,
+		&token.Revoked,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return RefreshToken{}, ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return RefreshToken{}, err
+	}
+
+	if roles != "" {
+		token.Roles = strings.Split(roles, roleDelimiter)
+	}
+	if extra.Valid && extra.String != "" {
+		err = json.Unmarshal([]byte(extra.String), &token.Extra)
+		if err != nil {
+			return RefreshToken{}, fmt.Errorf("failed to decode extra claims: %w", err)
+		}
+	}
+
+	return token, nil
+}
+
+const markRefreshTokenUsedQuery = `UPDATE refresh_token SET used = ? WHERE id = ? AND used = ? AND revoked = ?`
+
+// Rotate marks a token as used and saves its successor in one transaction,
+// returning false if the token already was used or has been revoked.
+func (s *SQLRefreshTokenStore) Rotate(ctx context.Context, id string, next RefreshToken) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := tx.ExecContext(ctx, markRefreshTokenUsedQuery, true, id, false, false)
+	if err != nil {
+		dbutil.Rollback(tx)
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil || rows != 1 {
+		dbutil.Rollback(tx)
+		return false, err
+	}
+
+	err = saveRefreshToken(ctx, tx, next)
+	if err != nil {
+		dbutil.Rollback(tx)
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+const revokeRefreshTokenFamilyQuery = `UPDATE refresh_token SET revoked = ? WHERE family_id = ?`
+
+// RevokeFamily revokes all tokens in a token family.
+func (s *SQLRefreshTokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	_, err := s.db.ExecContext(ctx, revokeRefreshTokenFamilyQuery, true, familyID)
+	return err
+}
+
+const revokeRefreshTokenSessionQuery = `UPDATE refresh_token SET revoked = ? WHERE session_id = ?`
+
+// RevokeSession revokes all tokens issued for a session.
+func (s *SQLRefreshTokenStore) RevokeSession(ctx context.Context, sessionID string) error {
+	_, err := s.db.ExecContext(ctx, revokeRefreshTokenSessionQuery, true, sessionID)
+	return err
+}
@@ -0,0 +1,249 @@
+package jwt_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/httputil/jwt"
+	"github.com/CzarSimon/httputil/testutil"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshTokenRotationAndReuse(t *testing.T) {
+	stores, db := refreshTokenStores()
+	defer db.Close()
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			ctx := context.Background()
+			creds := jwt.Credentials{
+				Issuer: "issuer-name",
+				Secret: "super-secret-token",
+			}
+			tokens := jwt.NewRefreshTokens(jwt.NewIssuer(creds), store, time.Minute, time.Hour)
+			verifier := jwt.NewVerifier(creds, time.Minute)
+
+			user := jwt.User{
+				ID:       "user-id",
+				Roles:    []string{jwt.UserRole, jwt.AdminRole},
+				ClientID: "client-id",
+			}
+			first, err := tokens.Issue(ctx, user)
+			assert.NoError(err)
+			assert.NotEmpty(first.RefreshToken)
+			assert.Equal(60, first.ExpiresIn)
+
+			verified, err := verifier.Verify(first.AccessToken)
+			assert.NoError(err)
+			assert.NotEmpty(verified.SessionID)
+
+			second, err := tokens.Refresh(ctx, first.RefreshToken)
+			assert.NoError(err)
+			assert.NotEqual(first.RefreshToken, second.RefreshToken)
+
+			refreshed, err := verifier.Verify(second.AccessToken)
+			assert.NoError(err)
+			assert.Equal(user.ID, refreshed.ID)
+			assert.Equal(user.Roles, refreshed.Roles)
+			assert.Equal(user.ClientID, refreshed.ClientID)
+			assert.Equal(verified.SessionID, refreshed.SessionID)
+
+			_, err = tokens.Refresh(ctx, first.RefreshToken)
+			assert.True(errors.Is(err, jwt.ErrRefreshTokenReused), err)
+
+			_, err = tokens.Refresh(ctx, second.RefreshToken)
+			assert.True(errors.Is(err, jwt.ErrInvalidRefreshToken), err)
+
+			_, err = tokens.Refresh(ctx, "unknown-token")
+			assert.True(errors.Is(err, jwt.ErrInvalidRefreshToken), err)
+		})
+	}
+}
+
+func TestRefreshTokenRevocation(t *testing.T) {
+	stores, db := refreshTokenStores()
+	defer db.Close()
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			ctx := context.Background()
+			issuer := jwt.NewIssuer(jwt.Credentials{Issuer: "issuer-name", Secret: "super-secret-token"})
+			tokens := jwt.NewRefreshTokens(issuer, store, time.Minute, time.Hour)
+
+			pair, err := tokens.Issue(ctx, jwt.User{ID: "user-1", SessionID: "session-1", Roles: []string{jwt.UserRole}})
+			assert.NoError(err)
+			err = tokens.Revoke(ctx, pair.RefreshToken)
+			assert.NoError(err)
+			_, err = tokens.Refresh(ctx, pair.RefreshToken)
+			assert.True(errors.Is(err, jwt.ErrInvalidRefreshToken), err)
+
+			pair, err = tokens.Issue(ctx, jwt.User{ID: "user-2", SessionID: "session-2", Roles: []string{jwt.UserRole}})
+			assert.NoError(err)
+			other, err := tokens.Issue(ctx, jwt.User{ID: "user-3", SessionID: "session-3", Roles: []string{jwt.UserRole}})
+			assert.NoError(err)
+			err = tokens.RevokeSession(ctx, "session-2")
+			assert.NoError(err)
+			_, err = tokens.Refresh(ctx, pair.RefreshToken)
+			assert.True(errors.Is(err, jwt.ErrInvalidRefreshToken), err)
+			_, err = tokens.Refresh(ctx, other.RefreshToken)
+			assert.NoError(err)
+
+			expiring := jwt.NewRefreshTokens(issuer, store, time.Minute, -time.Second)
+			pair, err = expiring.Issue(ctx, jwt.User{ID: "user-4", Roles: []string{jwt.UserRole}})
+			assert.NoError(err)
+			_, err = expiring.Refresh(ctx, pair.RefreshToken)
+			assert.True(errors.Is(err, jwt.ErrInvalidRefreshToken), err)
+		})
+	}
+}
+
+func TestRefreshTokenKeepsClaims(t *testing.T) {
+	stores, db := refreshTokenStores()
+	defer db.Close()
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			ctx := context.Background()
+			creds := jwt.Credentials{
+				Issuer: "issuer-name",
+				Secret: "super-secret-token",
+			}
+			tokens := jwt.NewRefreshTokens(jwt.NewIssuer(creds), store, time.Minute, time.Hour)
+			verifier := jwt.NewVerifier(creds, time.Minute)
+
+			user := jwt.User{
+				ID:         "user-id",
+				Roles:      []string{jwt.UserRole},
+				ClientID:   "client-id",
+				OriginID:   "origin-id",
+				CareUnitID: "care-unit-id",
+				ActorID:    "actor-id",
+				Extra:      map[string]interface{}{"locale": "sv-SE", "level": 2.0},
+			}
+			pair, err := tokens.Issue(ctx, user)
+			assert.NoError(err)
+
+			for i := 0; i < 2; i++ {
+				pair, err = tokens.Refresh(ctx, pair.RefreshToken)
+				assert.NoError(err)
+			}
+
+			refreshed, err := verifier.Verify(pair.AccessToken)
+			assert.NoError(err)
+			assert.Equal(user.ID, refreshed.ID)
+			assert.Equal(user.ClientID, refreshed.ClientID)
+			assert.Equal(user.OriginID, refreshed.OriginID)
+			assert.Equal(user.CareUnitID, refreshed.CareUnitID)
+			assert.Equal(user.ActorID, refreshed.ActorID)
+			assert.Equal(user.Extra, refreshed.Extra)
+		})
+	}
+}
+
+type failingIssuer struct {
+	jwt.Issuer
+	fail bool
+}
+
+func (i *failingIssuer) Issue(user jwt.User, lifetime time.Duration, audience ...string) (string, error) {
+	if i.fail {
+		return "", errors.New("signer unavailable")
+	}
+
+	return i.Issuer.Issue(user, lifetime, audience...)
+}
+
+func TestRefreshTokenIssueFailureKeepsToken(t *testing.T) {
+	stores, db := refreshTokenStores()
+	defer db.Close()
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			ctx := context.Background()
+			issuer := &failingIssuer{Issuer: jwt.NewIssuer(jwt.Credentials{Issuer: "issuer-name", Secret: "super-secret-token"})}
+			tokens := jwt.NewRefreshTokens(issuer, store, time.Minute, time.Hour)
+
+			pair, err := tokens.Issue(ctx, jwt.User{ID: "user-id", Roles: []string{jwt.UserRole}})
+			assert.NoError(err)
+
+			issuer.fail = true
+			_, err = tokens.Refresh(ctx, pair.RefreshToken)
+			assert.Error(err)
+			assert.False(errors.Is(err, jwt.ErrRefreshTokenReused))
+
+			issuer.fail = false
+			_, err = tokens.Refresh(ctx, pair.RefreshToken)
+			assert.NoError(err)
+		})
+	}
+}
+
+// revokingStore store revoking the token family of every token found, as if it was revoked concurrently.
+type revokingStore struct {
+	jwt.RefreshTokenStore
+	found jwt.RefreshToken
+}
+
+func (s *revokingStore) Find(ctx context.Context, id string) (jwt.RefreshToken, error) {
+	token, err := s.RefreshTokenStore.Find(ctx, id)
+	if err != nil || token.Revoked {
+		return token, err
+	}
+
+	s.found = token
+	return token, s.RefreshTokenStore.RevokeFamily(ctx, token.FamilyID)
+}
+
+func TestRefreshTokenRevokedBeforeRotation(t *testing.T) {
+	stores, db := refreshTokenStores()
+	defer db.Close()
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			ctx := context.Background()
+			issuer := jwt.NewIssuer(jwt.Credentials{Issuer: "issuer-name", Secret: "super-secret-token"})
+
+			pair, err := jwt.NewRefreshTokens(issuer, store, time.Minute, time.Hour).Issue(ctx, jwt.User{ID: "user-id", Roles: []string{jwt.UserRole}})
+			assert.NoError(err)
+
+			revoking := &revokingStore{RefreshTokenStore: store}
+			tokens := jwt.NewRefreshTokens(issuer, revoking, time.Minute, time.Hour)
+			_, err = tokens.Refresh(ctx, pair.RefreshToken)
+			assert.True(errors.Is(err, jwt.ErrInvalidRefreshToken), err)
+			assert.False(errors.Is(err, jwt.ErrRefreshTokenReused))
+
+			token, err := store.Find(ctx, revoking.found.ID)
+			assert.NoError(err)
+			assert.True(token.Revoked)
+			assert.False(token.Used)
+
+			next := token
+			next.ID = "successor-id"
+			next.Revoked = false
+			ok, err := store.Rotate(ctx, token.ID, next)
+			assert.NoError(err)
+			assert.False(ok)
+			_, err = store.Find(ctx, next.ID)
+			assert.True(errors.Is(err, jwt.ErrRefreshTokenNotFound), err)
+		})
+	}
+}
+
+func refreshTokenStores() (map[string]jwt.RefreshTokenStore, *sql.DB) {
+	db := testutil.InMemoryDB(true, "./resources/test_migrations")
+	db.SetMaxOpenConns(1)
+
+	return map[string]jwt.RefreshTokenStore{
+		"memory": jwt.NewMemoryRefreshTokenStore(),
+		"sql":    jwt.NewSQLRefreshTokenStore(db),
+	}, db
+}
@@ -0,0 +1,202 @@
+package jwt
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// Default remote key source settings.
+const (
+	DefaultJWKSCacheTTL           = 15 * time.Minute
+	DefaultJWKSMinRefreshInterval = 10 * time.Second
+)
+
+const jwksRequestTimeout = 10 * time.Second
+
+// RemoteKeySource fetches and caches the JSON Web Key Set published by another service.
+// The key set is refreshed when the cache expires or when a token with an unknown key ID
+// is encountered, at most once per min refresh interval. Expired keys are served while the
+// key set is refreshed in the background, and are kept if the refresh fails. Concurrent
+// refreshes are collapsed into a single request, which is made without holding the lock.
+type RemoteKeySource struct {
+	url                string
+	client             *http.Client
+	cacheTTL           time.Duration
+	minRefreshInterval time.Duration
+
+	mu          sync.Mutex
+	keys        map[string]Key
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	inflight    *jwksFetch
+}
+
+// jwksFetch in-flight fetch of a key set, done is closed once it has completed.
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// NewRemoteKeySource creates a new RemoteKeySource fetching keys from a JWKS url.
+func NewRemoteKeySource(url string, cacheTTL, minRefreshInterval time.Duration) *RemoteKeySource {
+	return &RemoteKeySource{
+		url: url,
+		client: &http.Client{
+			Timeout: jwksRequestTimeout,
+		},
+		cacheTTL:           cacheTTL,
+		minRefreshInterval: minRefreshInterval,
+		keys:               make(map[string]Key),
+	}
+}
+
+// NewRemoteVerifier creates a new Verifier using the keys published at a JWKS url with the default cache settings.
+// If an expected audience is supplied, only tokens issued for any of them are accepted.
+func NewRemoteVerifier(issuer, jwksURL string, leeway time.Duration, audience ...string) Verifier {
+	keys := NewRemoteKeySource(jwksURL, DefaultJWKSCacheTTL, DefaultJWKSMinRefreshInterval)
+	return NewKeySetVerifier(issuer, keys, leeway, audience...)
+}
+
+// VerificationKey returns the key with the given ID, fetching the key set if needed.
+// Tokens without a key ID are only accepted if the remote key set holds a single key.
+func (s *RemoteKeySource) VerificationKey(kid string) (Key, error) {
+	now := time.Now()
+	s.mu.Lock()
+	key, ok := s.lookup(kid)
+	if ok {
+		if now.After(s.fetchedAt.Add(s.cacheTTL)) {
+			s.startRefresh(now, false)
+		}
+		s.mu.Unlock()
+		return key, nil
+	}
+
+	fetch := s.startRefresh(now, false)
+	s.mu.Unlock()
+	if fetch == nil {
+		return Key{}, fmt.Errorf("%w: %s", ErrUnknownKeyID, kid)
+	}
+
+	<-fetch.done
+	s.mu.Lock()
+	key, ok = s.lookup(kid)
+	s.mu.Unlock()
+	if !ok {
+		return Key{}, fmt.Errorf("%w: %s", ErrUnknownKeyID, kid)
+	}
+
+	return key, nil
+}
+
+// Refresh fetches the remote key set.
+func (s *RemoteKeySource) Refresh() error {
+	s.mu.Lock()
+	fetch := s.startRefresh(time.Now(), true)
+	s.mu.Unlock()
+
+	<-fetch.done
+	return fetch.err
+}
+
+func (s *RemoteKeySource) lookup(kid string) (Key, bool) {
+	if kid != "" {
+		key, ok := s.keys[kid]
+		return key, ok
+	}
+
+	if len(s.keys) != 1 {
+		return Key{}, false
+	}
+	for _, key := range s.keys {
+		return key, true
+	}
+
+	return Key{}, false
+}
+
+// startRefresh returns the in-flight fetch of the key set, starting one unless forced or the min
+// refresh interval has passed since the last attempt. Returns nil if no fetch is allowed yet.
+// Must be called with the lock held.
+func (s *RemoteKeySource) startRefresh(now time.Time, force bool) *jwksFetch {
+	if s.inflight != nil {
+		return s.inflight
+	}
+	if !force && !now.After(s.lastAttempt.Add(s.minRefreshInterval)) {
+		return nil
+	}
+
+	fetch := &jwksFetch{done: make(chan struct{})}
+	s.inflight = fetch
+	s.lastAttempt = now
+	go s.refresh(fetch)
+	return fetch
+}
+
+// refresh fetches the key set and swaps it in, keeping the cached keys if the fetch fails.
+func (s *RemoteKeySource) refresh(fetch *jwksFetch) {
+	keys, err := s.fetch()
+	if err != nil {
+		log.Warn("Failed to refresh JWKS", zap.String("url", s.url), zap.Error(err))
+	}
+
+	s.mu.Lock()
+	if err == nil {
+		s.keys = keys
+		s.fetchedAt = time.Now()
+	}
+	s.inflight = nil
+	s.mu.Unlock()
+
+	fetch.err = err
+	close(fetch.done)
+}
+
+func (s *RemoteKeySource) fetch() (map[string]Key, error) {
+	res, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected JWKS response status: %s", res.Status)
+	}
+
+	var jwks jose.JSONWebKeySet
+	err = json.NewDecoder(res.Body).Decode(&jwks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]Key, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		key, ok := verificationKeyFromJWK(jwk)
+		if ok {
+			keys[key.ID] = key
+		}
+	}
+
+	return keys, nil
+}
+
+// verificationKeyFromJWK converts a public signature JWK into a Key, skipping keys whose
+// declared algorithm does not match the key type.
+func verificationKeyFromJWK(jwk jose.JSONWebKey) (Key, bool) {
+	if !jwk.IsPublic() || (jwk.Use != "" && jwk.Use != "sig") {
+		return Key{}, false
+	}
+
+	key := Key{ID: jwk.KeyID, Key: jwk.Key}
+	alg, err := key.algorithm()
+	if err != nil || (jwk.Algorithm != "" && jwk.Algorithm != string(alg)) {
+		return Key{}, false
+	}
+
+	return key, true
+}
@@ -0,0 +1,17 @@
+-- +migrate Up
+CREATE TABLE `refresh_token` (
+  `id` VARCHAR(64) PRIMARY KEY,
+  `family_id` VARCHAR(50) NOT NULL,
+  `session_id` VARCHAR(50) NOT NULL,
+  `user_id` VARCHAR(50) NOT NULL,
+  `roles` VARCHAR(255) NOT NULL,
+  `client_id` VARCHAR(50) NOT NULL,
+  `created_at` DATETIME NOT NULL,
+  `expires_at` DATETIME NOT NULL,
+  `used` BOOLEAN NOT NULL DEFAULT FALSE,
+  `revoked` BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE INDEX `refresh_token_family_idx` ON `refresh_token` (`family_id`);
+CREATE INDEX `refresh_token_session_idx` ON `refresh_token` (`session_id`);
+-- +migrate Down
+DROP TABLE IF EXISTS `refresh_token`;
@@ -0,0 +1,8 @@
+-- +migrate Up
+CREATE TABLE `revoked_token` (
+  `id` VARCHAR(50) PRIMARY KEY,
+  `expires_at` DATETIME NOT NULL
+);
+CREATE INDEX `revoked_token_expires_at_idx` ON `revoked_token` (`expires_at`);
+-- +migrate Down
+DROP TABLE IF EXISTS `revoked_token`;
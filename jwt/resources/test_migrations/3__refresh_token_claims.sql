@@ -0,0 +1,10 @@
+-- +migrate Up
+ALTER TABLE `refresh_token` ADD COLUMN `origin_id` VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE `refresh_token` ADD COLUMN `care_unit_id` VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE `refresh_token` ADD COLUMN `actor_id` VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE `refresh_token` ADD COLUMN `extra` TEXT;
+-- +migrate Down
+ALTER TABLE `refresh_token` DROP COLUMN `extra`;
+ALTER TABLE `refresh_token` DROP COLUMN `actor_id`;
+ALTER TABLE `refresh_token` DROP COLUMN `care_unit_id`;
+ALTER TABLE `refresh_token` DROP COLUMN `origin_id`;
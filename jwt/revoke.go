@@ -0,0 +1,194 @@
+package jwt
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Revoker keeps track of access tokens that have been revoked before their expiry.
+// Entries only need to be kept until the revoked token expires.
+type Revoker interface {
+	// Revoke revokes the token with the given ID (jti) until it expires.
+	Revoke(tokenID string, expiresAt time.Time) error
+	// IsRevoked checks if the token with the given ID has been revoked.
+	IsRevoked(tokenID string) (bool, error)
+}
+
+// RevokeUser revokes the token a verified user was authenticated with.
+func RevokeUser(revoker Revoker, user User) error {
+	if user.TokenID == "" {
+		return fmt.Errorf("%w: token has no id", ErrInvalidTokenContent)
+	}
+
+	return revoker.Revoke(user.TokenID, user.ExpiresAt)
+}
+
+// NewRevokingVerifier wraps a Verifier, rejecting tokens that have been revoked with ErrRevokedToken.
+// Tokens without a token ID cannot be revoked and are accepted. If the revoker fails the token is rejected.
+func NewRevokingVerifier(verifier Verifier, revoker Revoker) Verifier {
+	return &revokingVerifier{
+		verifier: verifier,
+		revoker:  revoker,
+	}
+}
+
+type revokingVerifier struct {
+	verifier Verifier
+	revoker  Revoker
+}
+
+func (v *revokingVerifier) Verify(token string) (User, error) {
+	user, err := v.verifier.Verify(token)
+	if err != nil || user.TokenID == "" {
+		return user, err
+	}
+
+	revoked, err := v.revoker.IsRevoked(user.TokenID)
+	if err != nil {
+		log.Error("Failed to check token revocation", zap.String("tokenId", user.TokenID), zap.Error(err))
+		return User{}, ErrInvalidToken
+	}
+	if revoked {
+		return User{}, ErrRevokedToken
+	}
+
+	return user, nil
+}
+
+// MemoryRevoker in memory Revoker, suitable for tests and single instance services.
+type MemoryRevoker struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+// NewMemoryRevoker creates a new, empty MemoryRevoker.
+func NewMemoryRevoker() *MemoryRevoker {
+	return &MemoryRevoker{
+		revoked: make(map[string]time.Time),
+	}
+}
+
+// Revoke revokes the token with the given ID until it expires.
+func (r *MemoryRevoker) Revoke(tokenID string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.prune(time.Now())
+	r.revoked[tokenID] = expiresAt
+	return nil
+}
+
+// IsRevoked checks if the token with the given ID has been revoked.
+func (r *MemoryRevoker) IsRevoked(tokenID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	expiresAt, ok := r.revoked[tokenID]
+	if !ok {
+		return false, nil
+	}
+
+	if time.Now().After(expiresAt) {
+		delete(r.revoked, tokenID)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// Len returns the number of revoked tokens that have not yet expired.
+func (r *MemoryRevoker) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.prune(time.Now())
+	return len(r.revoked)
+}
+
+func (r *MemoryRevoker) prune(now time.Time) {
+	for tokenID, expiresAt := range r.revoked {
+		if now.After(expiresAt) {
+			delete(r.revoked, tokenID)
+		}
+	}
+}
+
+// SQLRevoker Revoker backed by a SQL database connected through dbutil. Expired entries are ignored,
+// and should be removed periodically with Prune or PruneEvery. Expects a table on the following form to exist:
+//
+//	CREATE TABLE `revoked_token` (
+//	  `id` VARCHAR(50) PRIMARY KEY,
+//	  `expires_at` DATETIME NOT NULL
+//	);
+type SQLRevoker struct {
+	db          *sql.DB
+	revokeQuery string
+}
+
+// NewSQLRevoker creates a new SQLRevoker for a database using the given driver, e.g. mysql or sqlite3.
+func NewSQLRevoker(db *sql.DB, driver string) *SQLRevoker {
+	revokeQuery := `INSERT INTO revoked_token(id, expires_at) VALUES (?, ?) ON CONFLICT(id) DO NOTHING`
+	if driver == "mysql" {
+		revokeQuery = `INSERT IGNORE INTO revoked_token(id, expires_at) VALUES (?, ?)`
+	}
+
+	return &SQLRevoker{
+		db:          db,
+		revokeQuery: revokeQuery,
+	}
+}
+
+const (
+	isTokenRevokedQuery    = `SELECT expires_at FROM revoked_token WHERE id = ?`
+	pruneRevokedTokenQuery = `DELETE FROM revoked_token WHERE expires_at < ?`
+)
+
+// Revoke revokes the token with the given ID until it expires. Revoking an already revoked token is a no-op.
+func (r *SQLRevoker) Revoke(tokenID string, expiresAt time.Time) error {
+	_, err := r.db.Exec(r.revokeQuery, tokenID, expiresAt.UTC())
+	return err
+}
+
+// IsRevoked checks if the token with the given ID has been revoked.
+func (r *SQLRevoker) IsRevoked(tokenID string) (bool, error) {
+	var expiresAt time.Time
+	err := r.db.QueryRow(isTokenRevokedQuery, tokenID).Scan(&expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return time.Now().Before(expiresAt), nil
+}
+
+// Prune removes entries for revoked tokens that have expired.
+func (r *SQLRevoker) Prune() error {
+	_, err := r.db.Exec(pruneRevokedTokenQuery, time.Now().UTC())
+	return err
+}
+
+// PruneEvery prunes expired entries at the given interval until the context is done.
+func (r *SQLRevoker) PruneEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := r.Prune()
+			if err != nil {
+				log.Warn("Failed to prune revoked tokens", zap.Error(err))
+			}
+		}
+	}
+}
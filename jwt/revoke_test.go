@@ -0,0 +1,113 @@
+package jwt_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/httputil/jwt"
+	"github.com/CzarSimon/httputil/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevokingVerifier(t *testing.T) {
+	db := testutil.InMemoryDB(true, "./resources/test_migrations")
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	revokers := map[string]jwt.Revoker{
+		"memory": jwt.NewMemoryRevoker(),
+		"sql":    jwt.NewSQLRevoker(db, "sqlite3"),
+	}
+
+	for name, revoker := range revokers {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			creds := jwt.Credentials{
+				Issuer: "issuer-name",
+				Secret: "super-secret-token",
+			}
+			issuer := jwt.NewIssuer(creds)
+			verifier := jwt.NewRevokingVerifier(jwt.NewVerifier(creds, time.Minute), revoker)
+			user := jwt.User{
+				ID:    "user-id",
+				Roles: []string{jwt.UserRole},
+			}
+
+			token, err := issuer.Issue(user, time.Hour)
+			assert.NoError(err)
+			other, err := issuer.Issue(user, time.Hour)
+			assert.NoError(err)
+
+			verified, err := verifier.Verify(token)
+			assert.NoError(err)
+			assert.NotEmpty(verified.TokenID)
+			assert.WithinDuration(time.Now().Add(time.Hour), verified.ExpiresAt, time.Minute)
+
+			err = jwt.RevokeUser(revoker, verified)
+			assert.NoError(err)
+			err = jwt.RevokeUser(revoker, verified)
+			assert.NoError(err)
+
+			_, err = verifier.Verify(token)
+			assert.True(errors.Is(err, jwt.ErrRevokedToken), err)
+
+			_, err = verifier.Verify(other)
+			assert.NoError(err)
+
+			err = revoker.Revoke("expired-token-id", time.Now().Add(-time.Second))
+			assert.NoError(err)
+			revoked, err := revoker.IsRevoked("expired-token-id")
+			assert.NoError(err)
+			assert.False(revoked)
+
+			err = jwt.RevokeUser(revoker, jwt.User{ID: "user-id"})
+			assert.True(errors.Is(err, jwt.ErrInvalidTokenContent), err)
+		})
+	}
+}
+
+func TestMemoryRevokerExpiry(t *testing.T) {
+	assert := assert.New(t)
+	revoker := jwt.NewMemoryRevoker()
+
+	err := revoker.Revoke("token-1", time.Now().Add(-time.Second))
+	assert.NoError(err)
+	err = revoker.Revoke("token-2", time.Now().Add(time.Hour))
+	assert.NoError(err)
+
+	assert.Equal(1, revoker.Len())
+}
+
+func TestSQLRevokerConcurrentRevokeAndPrune(t *testing.T) {
+	assert := assert.New(t)
+	db := testutil.InMemoryDB(true, "./resources/test_migrations")
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	revoker := jwt.NewSQLRevoker(db, "sqlite3")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- revoker.Revoke("token-id", time.Now().Add(time.Hour))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(err)
+	}
+
+	err := revoker.Revoke("expired-token-id", time.Now().Add(-time.Second))
+	assert.NoError(err)
+	assert.NoError(revoker.Prune())
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM revoked_token").Scan(&count)
+	assert.NoError(err)
+	assert.Equal(1, count)
+}
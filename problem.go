@@ -0,0 +1,108 @@
+package httputil
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// MIMEProblemJSON content type of RFC 7807 problem details.
+const MIMEProblemJSON = "application/problem+json"
+
+const defaultProblemType = "about:blank"
+
+// Problem RFC 7807 problem details document.
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+// NewProblem creates problem details from an Error, using the error ID as instance.
+// The error code and field violations are included as the "code" and "violations" extension members.
+func NewProblem(err *Error) Problem {
+	extensions := err.Extensions
+	if err.Code != "" || len(err.Violations) > 0 {
+		extensions = make(map[string]interface{}, len(err.Extensions)+2)
+		for key, value := range err.Extensions {
+			extensions[key] = value
+		}
+	}
+	if err.Code != "" {
+		extensions["code"] = err.Code
+	}
+	if len(err.Violations) > 0 {
+		extensions["violations"] = err.Violations
+	}
+
+	return Problem{
+		Type:       defaultProblemType,
+		Title:      http.StatusText(err.Status),
+		Status:     err.Status,
+		Detail:     err.Message,
+		Instance:   err.ID,
+		Extensions: extensions,
+	}
+}
+
+// MarshalJSON serializes the problem with extension members at the top level.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(p.Extensions)+5)
+	for key, value := range p.Extensions {
+		members[key] = value
+	}
+
+	members["type"] = p.Type
+	members["title"] = p.Title
+	members["status"] = p.Status
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+
+	return json.Marshal(members)
+}
+
+// UnmarshalJSON parses a problem, collecting unknown members as extensions.
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+	err := json.Unmarshal(data, &members)
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]interface{}{
+		"type":     &p.Type,
+		"title":    &p.Title,
+		"status":   &p.Status,
+		"detail":   &p.Detail,
+		"instance": &p.Instance,
+	}
+
+	for key, raw := range members {
+		if field, ok := fields[key]; ok {
+			err = json.Unmarshal(raw, field)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		var value interface{}
+		err = json.Unmarshal(raw, &value)
+		if err != nil {
+			return err
+		}
+
+		if p.Extensions == nil {
+			p.Extensions = make(map[string]interface{})
+		}
+		p.Extensions[key] = value
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package httputil
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -38,14 +39,19 @@ func MustGetPrincipal(c *gin.Context) (jwt.User, error) {
 }
 
 // RBAC adds role based access controll checks extracting roles from jwt.
+// If a Revoker is set, tokens revoked before their expiry are rejected.
 type RBAC struct {
-	Verifier jwt.Verifier
+	Verifier    jwt.Verifier
+	Permissions jwt.RolePermissions
+	Hierarchy   *jwt.RoleHierarchy
+	Revoker     jwt.Revoker
 }
 
-// NewRBAC creates a new RBAC struct with sane defaults.
-func NewRBAC(creds jwt.Credentials) RBAC {
+// NewRBAC creates a new RBAC struct with sane defaults. If an audience is
+// supplied, only tokens issued for the audience are accepted.
+func NewRBAC(creds jwt.Credentials, audience ...string) RBAC {
 	return RBAC{
-		Verifier: jwt.NewVerifier(creds, time.Minute),
+		Verifier: jwt.NewVerifier(creds, time.Minute, audience...),
 	}
 }
 
@@ -59,20 +65,11 @@ func (r *RBAC) Secure(roles ...string) gin.HandlerFunc {
 	}
 
 	return func(c *gin.Context) {
-		user, err := extractUserFromRequest(c, r.Verifier)
-		if err != nil {
-			logError(c, err)
-			c.AbortWithStatusJSON(err.Status, err)
+		user, ok := r.authenticate(c)
+		if !ok {
 			return
 		}
 
-		span := opentracing.SpanFromContext(c.Request.Context())
-		if span != nil {
-			span.SetBaggageItem("user-id", user.ID)
-			span.SetBaggageItem("user-roles", strings.Join(user.Roles, ";"))
-		}
-		c.Set(userKey, user)
-
 		for _, role := range validRoles {
 			if user.HasRole(role) {
 				c.Next()
@@ -81,12 +78,114 @@ func (r *RBAC) Secure(roles ...string) gin.HandlerFunc {
 		}
 
 		msg := fmt.Sprintf("%s %s access denied for %s", c.Request.Method, c.Request.URL.Path, user)
-		err = ForbiddenError(errors.New(msg))
-		logError(c, err)
-		c.AbortWithStatusJSON(err.Status, err)
+		err := ForbiddenError(errors.New(msg))
+		abortWithError(c, err)
+	}
+}
+
+// RequirePermissions checks if a request was made with a jwt whose roles grant all of a list of permissions.
+func (r *RBAC) RequirePermissions(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := r.authenticate(c)
+		if !ok {
+			return
+		}
+
+		missing := user.MissingPermissions(permissions...)
+		if len(missing) == 0 {
+			c.Next()
+			return
+		}
+
+		msg := fmt.Sprintf("%s %s access denied for %s, missing permissions: %v", c.Request.Method, c.Request.URL.Path, user, missing)
+		err := ForbiddenError(errors.New(msg))
+		abortWithError(c, err)
+	}
+}
+
+// RequireAnyPermission checks if a request was made with a jwt whose roles grant any of a list of permissions.
+func (r *RBAC) RequireAnyPermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := r.authenticate(c)
+		if !ok {
+			return
+		}
+
+		if user.HasAnyPermission(permissions...) {
+			c.Next()
+			return
+		}
+
+		msg := fmt.Sprintf("%s %s access denied for %s, missing any of permissions: %v", c.Request.Method, c.Request.URL.Path, user, permissions)
+		err := ForbiddenError(errors.New(msg))
+		abortWithError(c, err)
 	}
 }
 
+// Authenticate verifies the request token if one is present and stores the principal in the context.
+// Requests without a token proceed with an anonymous principal, while requests with an invalid token are rejected.
+func (r *RBAC) Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			r.setPrincipal(c, jwt.AnonymousUser())
+			c.Next()
+			return
+		}
+
+		_, ok := r.authenticate(c)
+		if !ok {
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// authenticate verifies the request token and stores the principal in the context,
+// aborting the request if the token is missing or invalid.
+func (r *RBAC) authenticate(c *gin.Context) (jwt.User, bool) {
+	verifier := r.Verifier
+	if r.Revoker != nil {
+		verifier = jwt.NewRevokingVerifier(verifier, r.Revoker)
+	}
+
+	user, err := extractUserFromRequest(c, verifier)
+	if err != nil {
+		abortWithError(c, err)
+		return jwt.User{}, false
+	}
+
+	return r.setPrincipal(c, user), true
+}
+
+// setPrincipal applies the role hierarchy and permissions to a user and stores it as the request principal.
+func (r *RBAC) setPrincipal(c *gin.Context, user jwt.User) jwt.User {
+	if r.Hierarchy != nil {
+		user = r.Hierarchy.Apply(user)
+	}
+	if r.Permissions != nil {
+		user = r.Permissions.Grant(user)
+	}
+
+	span := opentracing.SpanFromContext(c.Request.Context())
+	if span != nil {
+		span.SetBaggageItem("user-id", user.ID)
+		span.SetBaggageItem("user-roles", strings.Join(user.Roles, ";"))
+		if user.SessionID != "" {
+			span.SetBaggageItem("session-id", user.SessionID)
+		}
+		if user.ClientID != "" {
+			span.SetBaggageItem("client-id", user.ClientID)
+		}
+		if user.ActorID != "" {
+			span.SetBaggageItem("actor-id", user.ActorID)
+		}
+	}
+	c.Set(userKey, user)
+
+	return user
+}
+
 func extractUserFromRequest(c *gin.Context, verifier jwt.Verifier) (jwt.User, *Error) {
 	token, err := exctractToken(c)
 	if err != nil {
@@ -94,8 +193,11 @@ func extractUserFromRequest(c *gin.Context, verifier jwt.Verifier) (jwt.User, *E
 	}
 
 	user, jwtErr := verifier.Verify(token)
+	if errors.Is(jwtErr, jwt.ErrRevokedToken) {
+		return jwt.User{}, NewError("Token has been revoked", http.StatusUnauthorized, jwtErr)
+	}
 	if jwtErr != nil {
-		return jwt.User{}, UnauthorizedError(err)
+		return jwt.User{}, UnauthorizedError(jwtErr)
 	}
 
 	return user, nil
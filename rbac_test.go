@@ -1,12 +1,17 @@
 package httputil_test
 
 import (
+	"encoding/json"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/CzarSimon/httputil"
 	"github.com/CzarSimon/httputil/jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -71,3 +76,177 @@ func TestRBAC_WithConstructor(t *testing.T) {
 	res = performTestRequest(r, req)
 	assert.Equal(http.StatusForbidden, res.Code)
 }
+
+func TestRBAC_RequirePermissions(t *testing.T) {
+	assert := assert.New(t)
+	r := httputil.NewRouter("httputil-test", func() error {
+		return nil
+	})
+	rbac := httputil.NewRBAC(getTestJWTCredentials())
+	rbac.Permissions = jwt.RolePermissions{
+		"USER":        {"orders:read"},
+		jwt.AdminRole: {"orders:read", "orders:write"},
+	}
+	r.GET("/orders", rbac.RequirePermissions("orders:read"), httputil.SendOK)
+	r.POST("/orders", rbac.RequirePermissions("orders:read", "orders:write"), httputil.SendOK)
+	r.GET("/any", rbac.RequireAnyPermission("orders:write", "users:read"), func(c *gin.Context) {
+		principal, err := httputil.MustGetPrincipal(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, principal.Permissions)
+	})
+
+	req := createTestRequest("/orders", http.MethodGet, "USER", nil)
+	res := performTestRequest(r, req)
+	assert.Equal(http.StatusOK, res.Code)
+
+	req = createTestRequest("/orders", http.MethodPost, "USER", nil)
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusForbidden, res.Code)
+
+	req = createTestRequest("/orders", http.MethodPost, jwt.AdminRole, nil)
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusOK, res.Code)
+
+	req = createTestRequest("/orders", http.MethodGet, "", nil)
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusUnauthorized, res.Code)
+
+	req = createTestRequest("/any", http.MethodGet, "USER", nil)
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusForbidden, res.Code)
+
+	req = createTestRequest("/any", http.MethodGet, jwt.AdminRole, nil)
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusOK, res.Code)
+	assert.JSONEq(`["orders:read", "orders:write"]`, res.Body.String())
+}
+
+func TestRBAC_RoleHierarchy(t *testing.T) {
+	assert := assert.New(t)
+	r := httputil.NewRouter("httputil-test", func() error {
+		return nil
+	})
+	rbac := httputil.NewRBAC(getTestJWTCredentials())
+	rbac.Hierarchy = jwt.DefaultRoleHierarchy()
+	r.GET("/test", rbac.Secure(jwt.UserRole), httputil.SendOK)
+	r.GET("/admin", rbac.Secure(jwt.AdminRole), httputil.SendOK)
+
+	for _, role := range []string{jwt.UserRole, jwt.AdminRole, jwt.SystemRole} {
+		req := createTestRequest("/test", http.MethodGet, role, nil)
+		res := performTestRequest(r, req)
+		assert.Equal(http.StatusOK, res.Code, role)
+	}
+
+	req := createTestRequest("/test", http.MethodGet, jwt.AnonymousRole, nil)
+	res := performTestRequest(r, req)
+	assert.Equal(http.StatusForbidden, res.Code)
+
+	req = createTestRequest("/admin", http.MethodGet, jwt.UserRole, nil)
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusForbidden, res.Code)
+
+	req = createTestRequest("/admin", http.MethodGet, jwt.SystemRole, nil)
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusOK, res.Code)
+}
+
+func TestRBAC_Authenticate(t *testing.T) {
+	assert := assert.New(t)
+	r := httputil.NewRouter("httputil-test", func() error {
+		return nil
+	})
+	rbac := httputil.NewRBAC(getTestJWTCredentials())
+	r.GET("/test", rbac.Authenticate(), func(c *gin.Context) {
+		principal, ok := httputil.GetPrincipal(c)
+		if !ok {
+			c.Error(httputil.InternalServerErrorf("principal missing"))
+			return
+		}
+
+		c.JSON(http.StatusOK, principal.Roles)
+	})
+
+	req := createTestRequest("/test", http.MethodGet, "", nil)
+	res := performTestRequest(r, req)
+	assert.Equal(http.StatusOK, res.Code)
+	assert.JSONEq(`["ANONYMOUS"]`, res.Body.String())
+
+	req = createTestRequest("/test", http.MethodGet, jwt.AdminRole, nil)
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusOK, res.Code)
+	assert.JSONEq(`["ADMIN"]`, res.Body.String())
+
+	req = createTestRequest("/test", http.MethodGet, "", nil)
+	req.Header.Set("Authorization", "Bearer invalid-token")
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func TestRBAC_SpanBaggage(t *testing.T) {
+	assert := assert.New(t)
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	r := httputil.NewRouter("httputil-test", func() error {
+		return nil
+	})
+	rbac := httputil.NewRBAC(getTestJWTCredentials())
+	r.GET("/test", rbac.Secure(jwt.UserRole), func(c *gin.Context) {
+		span := opentracing.SpanFromContext(c.Request.Context())
+		c.JSON(http.StatusOK, map[string]string{
+			"userId":    span.BaggageItem("user-id"),
+			"sessionId": span.BaggageItem("session-id"),
+			"clientId":  span.BaggageItem("client-id"),
+		})
+	})
+
+	token, err := jwt.NewIssuer(getTestJWTCredentials()).Issue(jwt.User{
+		ID:        "user-id",
+		Roles:     []string{jwt.UserRole},
+		SessionID: "session-id",
+		ClientID:  "client-id",
+	}, time.Hour)
+	assert.NoError(err)
+
+	req := createTestRequest("/test", http.MethodGet, "", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	res := performTestRequest(r, req)
+	assert.Equal(http.StatusOK, res.Code)
+	assert.JSONEq(`{"userId": "user-id", "sessionId": "session-id", "clientId": "client-id"}`, res.Body.String())
+}
+
+func TestRBAC_Revoker(t *testing.T) {
+	assert := assert.New(t)
+	r := httputil.NewRouter("httputil-test", func() error {
+		return nil
+	})
+	rbac := httputil.NewRBAC(getTestJWTCredentials())
+	rbac.Revoker = jwt.NewMemoryRevoker()
+	r.GET("/test", rbac.Secure(jwt.UserRole), httputil.SendOK)
+
+	req := createTestRequest("/test", http.MethodGet, jwt.UserRole, nil)
+	res := performTestRequest(r, req)
+	assert.Equal(http.StatusOK, res.Code)
+
+	user, err := rbac.Verifier.Verify(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "))
+	assert.NoError(err)
+	err = jwt.RevokeUser(rbac.Revoker, user)
+	assert.NoError(err)
+
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusUnauthorized, res.Code)
+
+	var body httputil.Error
+	err = json.NewDecoder(res.Body).Decode(&body)
+	assert.NoError(err)
+	assert.Equal("Token has been revoked", body.Message)
+
+	req = createTestRequest("/test", http.MethodGet, jwt.UserRole, nil)
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusOK, res.Code)
+}
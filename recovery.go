@@ -0,0 +1,46 @@
+package httputil
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var panicsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "panics_total",
+		Help: "The total number of recovered panics during request handling",
+	},
+	[]string{"route"},
+)
+
+// Recovery recovers from panics during request handling and responds with a 500 - Internal Server Error.
+// The panic and its stack trace is logged, the request span is tagged as failed and the
+// panics_total metric is incremented.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+
+			panicErr, ok := r.(error)
+			if !ok {
+				panicErr = fmt.Errorf("%v", r)
+			}
+
+			panicsTotal.WithLabelValues(c.FullPath()).Inc()
+			err := InternalServerError(fmt.Errorf("recovered from panic: %w", panicErr))
+			abortWithError(c, err)
+		}()
+
+		c.Next()
+	}
+}
@@ -0,0 +1,138 @@
+package httputil_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/CzarSimon/httputil"
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecovery(t *testing.T) {
+	assert := assert.New(t)
+	r := httputil.NewRouter("httputil-test", func() error {
+		return nil
+	})
+	r.GET("/panic", func(c *gin.Context) {
+		panic("something went very wrong")
+	})
+	r.GET("/panic-error", func(c *gin.Context) {
+		panic(errors.New("something went very wrong"))
+	})
+
+	panics := map[string]float64{
+		"/panic":       counterValue(t, "panics_total", map[string]string{"route": "/panic"}),
+		"/panic-error": counterValue(t, "panics_total", map[string]string{"route": "/panic-error"}),
+	}
+	for _, path := range []string{"/panic", "/panic-error"} {
+		req := createTestRequest(path, http.MethodGet, "", nil)
+		res := performTestRequest(r, req)
+		assert.Equal(http.StatusInternalServerError, res.Code)
+
+		var body httputil.Error
+		err := json.NewDecoder(res.Body).Decode(&body)
+		assert.NoError(err)
+		assert.NotEmpty(body.ID)
+		assert.Equal(http.StatusInternalServerError, body.Status)
+		assert.Equal("Internal Server Error", body.Message)
+	}
+
+	req := createTestRequest("/health", http.MethodGet, "", nil)
+	res := performTestRequest(r, req)
+	assert.Equal(http.StatusOK, res.Code)
+
+	req = createTestRequest("/metrics", http.MethodGet, "", nil)
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusOK, res.Code)
+	assert.Contains(res.Body.String(), `panics_total{route="/panic"}`)
+	for route, count := range panics {
+		assert.Equal(count+1, counterValue(t, "panics_total", map[string]string{"route": route}), route)
+	}
+}
+
+func TestRecovery_TracesAndLogsPanics(t *testing.T) {
+	assert := assert.New(t)
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	r := httputil.NewRouter("httputil-test", func() error {
+		return nil
+	})
+	r.GET("/panic", func(c *gin.Context) {
+		panic("something went very wrong")
+	})
+
+	logged := counterValue(t, "log_events_total", map[string]string{"name": "httputil/error-log", "level": "error"})
+	req := createTestRequest("/panic", http.MethodGet, "", nil)
+	res := performTestRequest(r, req)
+	assert.Equal(http.StatusInternalServerError, res.Code)
+	assert.Equal(logged+1, counterValue(t, "log_events_total", map[string]string{"name": "httputil/error-log", "level": "error"}))
+
+	spans := tracer.FinishedSpans()
+	assert.Len(spans, 1)
+	assert.Equal(true, spans[0].Tag("error"))
+	assert.Equal(uint16(http.StatusInternalServerError), spans[0].Tag("http.status_code"))
+}
+
+func TestRecovery_PanicInBaseMiddleware(t *testing.T) {
+	assert := assert.New(t)
+	opentracing.SetGlobalTracer(panickingTracer{})
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	r := httputil.NewRouter("httputil-test", func() error {
+		return nil
+	})
+	r.GET("/test", httputil.SendOK)
+
+	req := createTestRequest("/test", http.MethodGet, "", nil)
+	res := performTestRequest(r, req)
+	assert.Equal(http.StatusInternalServerError, res.Code)
+	assert.NotEmpty(res.Header().Get(httputil.RequestIDHeader))
+}
+
+type panickingTracer struct {
+	opentracing.NoopTracer
+}
+
+func (t panickingTracer) StartSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	panic("tracer failure")
+}
+
+// counterValue reads the value of a counter with the given labels from the default registry, 0 if not yet recorded.
+func counterValue(t *testing.T, name string, labels map[string]string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			if hasLabels(metric.GetLabel(), labels) {
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+
+	return 0
+}
+
+func hasLabels(pairs []*dto.LabelPair, labels map[string]string) bool {
+	matched := 0
+	for _, pair := range pairs {
+		value, ok := labels[pair.GetName()]
+		if ok && value == pair.GetValue() {
+			matched++
+		}
+	}
+
+	return matched == len(labels)
+}
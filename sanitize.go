@@ -0,0 +1,154 @@
+package httputil
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/CzarSimon/httputil/environ"
+)
+
+// DebugErrorsEnvironmentVariable environment variable toggling the debug mode of DefaultSanitizePolicy.
+const DebugErrorsEnvironmentVariable = "DEBUG_ERRORS"
+
+const (
+	maxStackDepth = 32
+	rootPkgPrefix = "github.com/CzarSimon/httputil."
+)
+
+// SanitizePolicy controls which error details are exposed to clients.
+// 5xx responses only ever expose the error ID and the generic status message.
+type SanitizePolicy struct {
+	// AllowedMessages 4xx messages that may be exposed, others are replaced by the status text.
+	AllowedMessages []string
+	// AllowCodedMessages exposes the message of 4xx errors carrying an error code.
+	AllowCodedMessages bool
+	// Debug includes the wrapped error chain and the stack trace captured when the error was created.
+	// Stack traces are only captured for 5xx errors, so 4xx errors are rendered with their error chain only.
+	Debug bool
+}
+
+// DefaultSanitizePolicy creates a policy exposing messages of coded 4xx errors,
+// with debug mode enabled if the DEBUG_ERRORS environment variable is true.
+func DefaultSanitizePolicy() SanitizePolicy {
+	debug, _ := strconv.ParseBool(environ.Get(DebugErrorsEnvironmentVariable, "false"))
+	return SanitizePolicy{
+		AllowCodedMessages: true,
+		Debug:              debug,
+	}
+}
+
+// Sanitize sanitizes rendered errors according to a policy.
+func Sanitize(policy SanitizePolicy) ErrorOption {
+	allowed := make(map[string]bool, len(policy.AllowedMessages))
+	for _, msg := range policy.AllowedMessages {
+		allowed[msg] = true
+	}
+
+	return func(cfg *errorConfig) {
+		cfg.sanitize = true
+		cfg.allowedMessages = allowed
+		cfg.allowCodedMessages = policy.AllowCodedMessages
+		cfg.debug = policy.Debug
+	}
+}
+
+// debugError error rendered with debug details.
+type debugError struct {
+	*Error
+	Cause []string `json:"cause,omitempty"`
+	Stack string   `json:"stack,omitempty"`
+}
+
+// sanitizeError returns a copy of the error only containing the details allowed by the config.
+func (cfg *errorConfig) sanitizeError(err *Error) *Error {
+	if !cfg.sanitize {
+		return err
+	}
+
+	genericMessage := http.StatusText(err.Status)
+	if err.Status >= http.StatusInternalServerError {
+		return &Error{
+			ID:      err.ID,
+			Status:  err.Status,
+			Message: genericMessage,
+		}
+	}
+
+	sanitized := *err
+	if !cfg.messageAllowed(err) {
+		sanitized.Message = genericMessage
+	}
+
+	return &sanitized
+}
+
+func (cfg *errorConfig) messageAllowed(err *Error) bool {
+	if err.Message == http.StatusText(err.Status) {
+		return true
+	}
+	if cfg.allowCodedMessages && err.Code != "" {
+		return true
+	}
+
+	return cfg.allowedMessages[err.Message]
+}
+
+// StackTrace returns the stack trace captured when the Error was created.
+func (err *Error) StackTrace() string {
+	if len(err.stack) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	frames := runtime.CallersFrames(err.stack)
+	skipping := true
+	for {
+		frame, more := frames.Next()
+		if skipping && isErrorConstructor(frame) && more {
+			continue
+		}
+		skipping = false
+
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+
+	return sb.String()
+}
+
+// errorChain returns the messages of the errors wrapped by an Error.
+func errorChain(err *Error) []string {
+	chain := make([]string, 0)
+	for wrapped := err.Err; wrapped != nil; wrapped = errors.Unwrap(wrapped) {
+		chain = append(chain, wrapped.Error())
+	}
+
+	return chain
+}
+
+// callers captures the stack of an Error with a status, skipping errors below 500.
+func callers(status int) []uintptr {
+	if status < http.StatusInternalServerError {
+		return nil
+	}
+
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(3, pcs)
+	return pcs[:n]
+}
+
+// isErrorConstructor checks if a frame belongs to the constructors of Error,
+// which are skipped in stack traces.
+func isErrorConstructor(frame runtime.Frame) bool {
+	if !strings.HasPrefix(frame.Function, rootPkgPrefix) {
+		return false
+	}
+
+	return strings.HasSuffix(frame.File, "/error.go") || strings.HasSuffix(frame.File, "/catalog.go")
+}
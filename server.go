@@ -0,0 +1,190 @@
+package httputil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/CzarSimon/httputil/logger"
+	"go.uber.org/zap"
+)
+
+var serverLog = logger.GetDefaultLogger("httputil/server")
+
+// Default server timeouts.
+const (
+	DefaultReadTimeout     = 10 * time.Second
+	DefaultWriteTimeout    = 10 * time.Second
+	DefaultIdleTimeout     = 120 * time.Second
+	DefaultShutdownTimeout = 30 * time.Second
+)
+
+// Exit causes
+const (
+	ExitSignal      = "SIGNAL"
+	ExitStopped     = "STOPPED"
+	ExitServerError = "SERVER_ERROR"
+)
+
+// ServerConfig configuration of a Server.
+type ServerConfig struct {
+	Addr         string
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// DrainPeriod time to wait after readiness starts failing before the server stops
+	// accepting new connections, giving load balancers time to stop routing traffic.
+	DrainPeriod time.Duration
+	// ShutdownTimeout max time to wait for in-flight requests and shutdown hooks.
+	ShutdownTimeout time.Duration
+}
+
+// ShutdownHook function to run when a Server is shut down.
+type ShutdownHook func(ctx context.Context) error
+
+// ExitReason describes why a Server stopped running.
+type ExitReason struct {
+	Cause  string
+	Signal os.Signal
+	Err    error
+}
+
+func (r ExitReason) String() string {
+	return fmt.Sprintf("ExitReason(cause=%s, signal=%v, err=%v)", r.Cause, r.Signal, r.Err)
+}
+
+// Server http server with graceful shutdown.
+type Server struct {
+	http            *http.Server
+	health          *HealthChecker
+	drainPeriod     time.Duration
+	shutdownTimeout time.Duration
+	hooks           []namedHook
+	stop            chan struct{}
+	stopOnce        sync.Once
+}
+
+type namedHook struct {
+	name string
+	fn   ShutdownHook
+}
+
+// NewServer creates a new server serving a handler, usually the router created by NewRouter.
+// Zero valued timeouts are replaced by the defaults. The health checker is marked as
+// shutting down when shutdown starts, it may be nil.
+func NewServer(cfg ServerConfig, handler http.Handler, health *HealthChecker) *Server {
+	return &Server{
+		http: &http.Server{
+			Addr:         cfg.Addr,
+			Handler:      handler,
+			ReadTimeout:  durationOrDefault(cfg.ReadTimeout, DefaultReadTimeout),
+			WriteTimeout: durationOrDefault(cfg.WriteTimeout, DefaultWriteTimeout),
+			IdleTimeout:  durationOrDefault(cfg.IdleTimeout, DefaultIdleTimeout),
+		},
+		health:          health,
+		drainPeriod:     cfg.DrainPeriod,
+		shutdownTimeout: durationOrDefault(cfg.ShutdownTimeout, DefaultShutdownTimeout),
+		stop:            make(chan struct{}),
+	}
+}
+
+// OnShutdown registers a named hook to run on shutdown. Hooks run in the order they are registered
+// after the server has stopped serving requests.
+func (s *Server) OnShutdown(name string, hook ShutdownHook) {
+	s.hooks = append(s.hooks, namedHook{name: name, fn: hook})
+}
+
+// CloseOnShutdown registers a closer, such as a *sql.DB, to be closed on shutdown.
+func (s *Server) CloseOnShutdown(name string, closer io.Closer) {
+	s.OnShutdown(name, func(ctx context.Context) error {
+		return closer.Close()
+	})
+}
+
+// Stop triggers a graceful shutdown of a running server.
+func (s *Server) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// Run starts the server and blocks until it has been shut down by a SIGINT or SIGTERM,
+// a call to Stop or a server error.
+func (s *Server) Run() ExitReason {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serverLog.Info("Starting server", zap.String("addr", s.http.Addr))
+		serveErr <- s.http.ListenAndServe()
+	}()
+
+	var reason ExitReason
+	select {
+	case sig := <-sigs:
+		reason = ExitReason{Cause: ExitSignal, Signal: sig}
+	case <-s.stop:
+		reason = ExitReason{Cause: ExitStopped}
+	case err := <-serveErr:
+		reason = ExitReason{Cause: ExitServerError, Err: err}
+	}
+
+	serverLog.Info("Shutting down server", zap.Stringer("reason", reason))
+	err := s.shutdown(reason.Cause != ExitServerError)
+	if reason.Err == nil {
+		reason.Err = err
+	}
+
+	return reason
+}
+
+func (s *Server) shutdown(drain bool) error {
+	if s.health != nil {
+		s.health.SetShuttingDown()
+	}
+
+	if drain && s.drainPeriod > 0 {
+		time.Sleep(s.drainPeriod)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	var firstErr error
+	err := s.http.Shutdown(ctx)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		serverLog.Error("Failed to gracefully shutdown server", zap.Error(err))
+		firstErr = err
+	}
+
+	for _, hook := range s.hooks {
+		err = hook.fn(ctx)
+		if err == nil {
+			continue
+		}
+
+		serverLog.Error("Shutdown hook failed", zap.String("hook", hook.name), zap.Error(err))
+		if firstErr == nil {
+			firstErr = fmt.Errorf("shutdown hook %s failed: %w", hook.name, err)
+		}
+	}
+
+	return firstErr
+}
+
+func durationOrDefault(d, defaultDuration time.Duration) time.Duration {
+	if d <= 0 {
+		return defaultDuration
+	}
+
+	return d
+}
@@ -0,0 +1,68 @@
+package httputil_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/httputil"
+	"github.com/stretchr/testify/assert"
+)
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+func TestServerGracefulShutdown(t *testing.T) {
+	assert := assert.New(t)
+	health := httputil.NewHealthChecker()
+	r := httputil.NewRouterWithHealth("httputil-test", health)
+	server := httputil.NewServer(httputil.ServerConfig{
+		Addr:        "127.0.0.1:0",
+		DrainPeriod: 10 * time.Millisecond,
+	}, r, health)
+
+	hookErr := errors.New("hook failed")
+	calls := make([]string, 0)
+	server.OnShutdown("first", func(ctx context.Context) error {
+		assert.True(health.ShuttingDown())
+		assert.False(health.Check(httputil.ReadinessProbe).Healthy())
+		calls = append(calls, "first")
+		return hookErr
+	})
+	server.CloseOnShutdown("db", closerFunc(func() error {
+		calls = append(calls, "db")
+		return nil
+	}))
+
+	assert.True(health.Check(httputil.ReadinessProbe).Healthy())
+	time.AfterFunc(20*time.Millisecond, server.Stop)
+	reason := server.Run()
+
+	assert.Equal(httputil.ExitStopped, reason.Cause)
+	assert.Nil(reason.Signal)
+	assert.True(errors.Is(reason.Err, hookErr))
+	assert.Equal([]string{"first", "db"}, calls)
+	assert.True(health.Check(httputil.LivenessProbe).Healthy())
+}
+
+func TestServerError(t *testing.T) {
+	assert := assert.New(t)
+	server := httputil.NewServer(httputil.ServerConfig{
+		Addr: "invalid-address",
+	}, httputil.NewRouter("httputil-test", func() error { return nil }), nil)
+
+	closed := false
+	server.CloseOnShutdown("db", closerFunc(func() error {
+		closed = true
+		return nil
+	}))
+
+	reason := server.Run()
+	assert.Equal(httputil.ExitServerError, reason.Cause)
+	assert.Error(reason.Err)
+	assert.True(closed)
+}
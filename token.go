@@ -0,0 +1,71 @@
+package httputil
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/CzarSimon/httputil/jwt"
+	"github.com/gin-gonic/gin"
+)
+
+// Token endpoint paths.
+const (
+	TokenRefreshPath = "/token/refresh"
+	TokenRevokePath  = "/token/revoke"
+)
+
+// RefreshTokenRequest request body to refresh or revoke a refresh token.
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// RefreshTokenHandler creates a handler exchanging a refresh token for a new token pair.
+// Invalid, expired and reused refresh tokens are rejected with 401 - Unauthorized.
+func RefreshTokenHandler(tokens *jwt.RefreshTokens) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RefreshTokenRequest
+		err := BindJSON(c, &req)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		pair, err := tokens.Refresh(c.Request.Context(), req.RefreshToken)
+		if err != nil {
+			c.Error(refreshTokenError(err))
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, pair)
+	}
+}
+
+// RevokeTokenHandler creates a handler revoking the token family of a refresh token.
+// Unknown tokens are treated as already revoked.
+func RevokeTokenHandler(tokens *jwt.RefreshTokens) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RefreshTokenRequest
+		err := BindJSON(c, &req)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		err = tokens.Revoke(c.Request.Context(), req.RefreshToken)
+		if err != nil && !errors.Is(err, jwt.ErrInvalidRefreshToken) {
+			c.Error(InternalServerError(err))
+			return
+		}
+
+		SendOK(c)
+	}
+}
+
+func refreshTokenError(err error) *Error {
+	if errors.Is(err, jwt.ErrInvalidRefreshToken) || errors.Is(err, jwt.ErrRefreshTokenReused) {
+		return UnauthorizedError(err)
+	}
+
+	return InternalServerError(err)
+}
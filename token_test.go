@@ -0,0 +1,75 @@
+package httputil_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CzarSimon/httputil"
+	"github.com/CzarSimon/httputil/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshAndRevokeTokenHandlers(t *testing.T) {
+	assert := assert.New(t)
+	creds := jwt.Credentials{
+		Issuer: "issuer-name",
+		Secret: "super-secret-token",
+	}
+	tokens := jwt.NewRefreshTokens(jwt.NewIssuer(creds), jwt.NewMemoryRefreshTokenStore(), time.Minute, time.Hour)
+
+	r := httputil.NewRouter("httputil-test", func() error {
+		return nil
+	})
+	r.POST(httputil.TokenRefreshPath, httputil.RefreshTokenHandler(tokens))
+	r.POST(httputil.TokenRevokePath, httputil.RevokeTokenHandler(tokens))
+
+	user := jwt.User{
+		ID:    "user-id",
+		Roles: []string{jwt.UserRole},
+	}
+	first, err := tokens.Issue(context.Background(), user)
+	assert.NoError(err)
+
+	res := postRefreshToken(r, httputil.TokenRefreshPath, first.RefreshToken)
+	assert.Equal(http.StatusOK, res.Code)
+	assert.Equal("no-store", res.Header().Get("Cache-Control"))
+
+	var second jwt.TokenPair
+	err = json.NewDecoder(res.Body).Decode(&second)
+	assert.NoError(err)
+	assert.NotEmpty(second.AccessToken)
+	assert.NotEqual(first.RefreshToken, second.RefreshToken)
+	assert.Equal("Bearer", second.TokenType)
+
+	verified, err := jwt.NewVerifier(creds, time.Minute).Verify(second.AccessToken)
+	assert.NoError(err)
+	assert.Equal(user.ID, verified.ID)
+
+	res = postRefreshToken(r, httputil.TokenRefreshPath, first.RefreshToken)
+	assert.Equal(http.StatusUnauthorized, res.Code)
+	res = postRefreshToken(r, httputil.TokenRefreshPath, second.RefreshToken)
+	assert.Equal(http.StatusUnauthorized, res.Code)
+
+	third, err := tokens.Issue(context.Background(), user)
+	assert.NoError(err)
+	res = postRefreshToken(r, httputil.TokenRevokePath, third.RefreshToken)
+	assert.Equal(http.StatusOK, res.Code)
+	res = postRefreshToken(r, httputil.TokenRefreshPath, third.RefreshToken)
+	assert.Equal(http.StatusUnauthorized, res.Code)
+
+	res = postRefreshToken(r, httputil.TokenRevokePath, "unknown-token")
+	assert.Equal(http.StatusOK, res.Code)
+
+	res = postRefreshToken(r, httputil.TokenRefreshPath, "")
+	assert.Equal(http.StatusBadRequest, res.Code)
+}
+
+func postRefreshToken(r http.Handler, path, refreshToken string) *httptest.ResponseRecorder {
+	body := httputil.RefreshTokenRequest{RefreshToken: refreshToken}
+	req := createTestRequest(path, http.MethodPost, "", body)
+	return performTestRequest(r, req)
+}
@@ -0,0 +1,218 @@
+package httputil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldViolation describes a request field that failed validation.
+type FieldViolation struct {
+	// Field JSON pointer (RFC 6901) to the offending field.
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func (v FieldViolation) String() string {
+	return fmt.Sprintf("FieldViolation(field=%s, rule=%s, param=%s)", v.Field, v.Rule, v.Param)
+}
+
+// BindJSON binds a JSON request body into v, converting binding
+// failures into a 400 - Bad Request error with field violations.
+func BindJSON(c *gin.Context, v interface{}) error {
+	return BindWith(c, v, binding.JSON)
+}
+
+// Bind binds a request into v using the binding matching the request method and content type,
+// converting binding failures into a 400 - Bad Request error with field violations.
+func Bind(c *gin.Context, v interface{}) error {
+	return BindWith(c, v, binding.Default(c.Request.Method, c.ContentType()))
+}
+
+// BindWith binds a request into v using the specified binding, converting
+// binding failures into a 400 - Bad Request error with field violations.
+func BindWith(c *gin.Context, v interface{}, b binding.Binding) error {
+	err := c.ShouldBindWith(v, b)
+	if err != nil {
+		return ValidationError(err, v)
+	}
+
+	return nil
+}
+
+// ValidationError creates a 400 - Bad Request error from a binding or validation error.
+// The value v that was bound is used to resolve json field names.
+func ValidationError(err error, v interface{}) *Error {
+	violations := getFieldViolations(err, reflect.TypeOf(v))
+	httpErr := BadRequestError(err)
+	httpErr.Violations = violations
+	return httpErr
+}
+
+func getFieldViolations(err error, t reflect.Type) []FieldViolation {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		violations := make([]FieldViolation, 0, len(validationErrs))
+		for _, fieldErr := range validationErrs {
+			violations = append(violations, newFieldViolation(fieldErr, t))
+		}
+		return violations
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return []FieldViolation{
+			{
+				Field:   jsonPointer(strings.Split(typeErr.Field, ".")),
+				Rule:    "type",
+				Param:   typeErr.Type.String(),
+				Message: fmt.Sprintf("%s must be of type %s", typeErr.Field, typeErr.Type),
+			},
+		}
+	}
+
+	return nil
+}
+
+func newFieldViolation(err validator.FieldError, t reflect.Type) FieldViolation {
+	path := jsonPath(err.StructNamespace(), t)
+	name := err.Field()
+	if len(path) > 0 {
+		name = path[len(path)-1]
+	}
+
+	return FieldViolation{
+		Field:   jsonPointer(path),
+		Rule:    err.Tag(),
+		Param:   err.Param(),
+		Message: violationMessage(name, err.Tag(), err.Param()),
+	}
+}
+
+func violationMessage(field, rule, param string) string {
+	switch rule {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "min", "gte":
+		return fmt.Sprintf("%s must be at least %s", field, param)
+	case "max", "lte":
+		return fmt.Sprintf("%s must be at most %s", field, param)
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, param)
+	case "lt":
+		return fmt.Sprintf("%s must be less than %s", field, param)
+	case "len":
+		return fmt.Sprintf("%s must have length %s", field, param)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of [%s]", field, param)
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", field)
+	case "url", "uri":
+		return fmt.Sprintf("%s must be a valid URL", field)
+	case "uuid", "uuid4":
+		return fmt.Sprintf("%s must be a valid UUID", field)
+	default:
+		return fmt.Sprintf("%s failed validation on the %s rule", field, rule)
+	}
+}
+
+// jsonPath converts a validator struct namespace such as Request.Items[0].Name
+// into the json path segments of the field, i.e. [items 0 name].
+func jsonPath(namespace string, t reflect.Type) []string {
+	segments := strings.Split(namespace, ".")
+	if len(segments) > 0 {
+		segments = segments[1:]
+	}
+
+	path := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		name, indices := splitIndices(segment)
+		t = derefType(t)
+
+		fieldName := name
+		if t != nil && t.Kind() == reflect.Struct {
+			field, ok := t.FieldByName(name)
+			if ok {
+				fieldName = jsonFieldName(field)
+				t = field.Type
+			} else {
+				t = nil
+			}
+		} else {
+			t = nil
+		}
+
+		if fieldName != "" {
+			path = append(path, fieldName)
+		}
+
+		for _, index := range indices {
+			path = append(path, index)
+			t = derefType(t)
+			if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map) {
+				t = t.Elem()
+			} else {
+				t = nil
+			}
+		}
+	}
+
+	return path
+}
+
+// jsonFieldName returns the json name of a struct field, empty if the field
+// is embedded and flattened into its parent.
+func jsonFieldName(field reflect.StructField) string {
+	tag := strings.Split(field.Tag.Get("json"), ",")[0]
+	if tag != "" && tag != "-" {
+		return tag
+	}
+	if field.Anonymous && tag == "" {
+		return ""
+	}
+
+	return field.Name
+}
+
+func splitIndices(segment string) (string, []string) {
+	start := strings.Index(segment, "[")
+	if start == -1 {
+		return segment, nil
+	}
+
+	name := segment[:start]
+	indices := make([]string, 0, 1)
+	for _, part := range strings.Split(segment[start+1:], "[") {
+		indices = append(indices, strings.TrimSuffix(part, "]"))
+	}
+
+	return name, indices
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t
+}
+
+func jsonPointer(path []string) string {
+	var sb strings.Builder
+	for _, segment := range path {
+		segment = strings.ReplaceAll(segment, "~", "~0")
+		segment = strings.ReplaceAll(segment, "/", "~1")
+		sb.WriteString("/")
+		sb.WriteString(segment)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,102 @@
+package httputil_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/CzarSimon/httputil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type testAddress struct {
+	Street string `json:"street" binding:"required"`
+	Zip    string `json:"zip" binding:"len=5"`
+}
+
+type testAuditInfo struct {
+	CreatedBy string `json:"createdBy" binding:"required"`
+}
+
+type testSignupRequest struct {
+	testAuditInfo
+	Email     string        `json:"email" binding:"required,email"`
+	Age       int           `json:"age" binding:"min=18"`
+	Role      string        `json:"role" binding:"oneof=USER ADMIN"`
+	Addresses []testAddress `json:"addresses" binding:"dive"`
+}
+
+func TestBindJSON(t *testing.T) {
+	assert := assert.New(t)
+	r := httputil.NewRouter("httputil-test", func() error {
+		return nil
+	})
+	r.POST("/test", func(c *gin.Context) {
+		var body testSignupRequest
+		err := httputil.BindJSON(c, &body)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		httputil.SendOK(c)
+	})
+
+	valid := map[string]interface{}{
+		"createdBy": "admin",
+		"email":     "mail@mail.com",
+		"age":       20,
+		"role":      "USER",
+		"addresses": []map[string]string{{"street": "Main street", "zip": "12345"}},
+	}
+	req := createTestRequest("/test", http.MethodPost, "", valid)
+	req.Header.Set("Content-Type", "application/json")
+	res := performTestRequest(r, req)
+	assert.Equal(http.StatusOK, res.Code)
+
+	invalid := map[string]interface{}{
+		"email":     "not-an-email",
+		"age":       12,
+		"role":      "USER",
+		"addresses": []map[string]string{{"street": "Main street", "zip": "12345"}, {"zip": "123"}},
+	}
+	req = createTestRequest("/test", http.MethodPost, "", invalid)
+	req.Header.Set("Content-Type", "application/json")
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusBadRequest, res.Code)
+
+	var body httputil.Error
+	err := json.NewDecoder(res.Body).Decode(&body)
+	assert.NoError(err)
+
+	violations := make(map[string]httputil.FieldViolation)
+	for _, v := range body.Violations {
+		violations[v.Field] = v
+	}
+	assert.Len(violations, 5)
+	assert.Equal("required", violations["/createdBy"].Rule)
+	assert.Equal("createdBy is required", violations["/createdBy"].Message)
+	assert.Equal("email", violations["/email"].Rule)
+	assert.Equal("min", violations["/age"].Rule)
+	assert.Equal("18", violations["/age"].Param)
+	assert.Equal("age must be at least 18", violations["/age"].Message)
+	assert.Equal("required", violations["/addresses/1/street"].Rule)
+	assert.Equal("len", violations["/addresses/1/zip"].Rule)
+	assert.Equal("5", violations["/addresses/1/zip"].Param)
+
+	req = createTestRequest("/test", http.MethodPost, "", nil)
+	req.Header.Set("Content-Type", "application/json")
+	req.Body = ioutil.NopCloser(strings.NewReader(`{"email": "mail@mail.com", "age": "twenty"}`))
+	res = performTestRequest(r, req)
+	assert.Equal(http.StatusBadRequest, res.Code)
+
+	body = httputil.Error{}
+	err = json.NewDecoder(res.Body).Decode(&body)
+	assert.NoError(err)
+	assert.Len(body.Violations, 1)
+	assert.Equal("/age", body.Violations[0].Field)
+	assert.Equal("type", body.Violations[0].Rule)
+}